@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers/github"
+
+	"github.com/fatih/color"
+)
+
+// GitHub App credentials used instead of stored GitHub tokens, e.g. in CI.
+// Either a pre-minted installation token or app ID, private key and
+// installation ID to create one with.
+type GitHubApp struct {
+	Token          string
+	ID             string
+	Key            string // path of the private key file or the PEM key itself
+	InstallationID string
+}
+
+var githubApp GitHubApp
+
+// Installation tokens created in this run, by API base URL
+var installationTokens = map[string]string{}
+
+// Use GitHub App credentials for all GitHub hosts
+func SetGitHubApp(app GitHubApp) {
+	githubApp = app
+}
+
+func (a GitHubApp) configured() bool {
+	return a.Token != "" || a.ID != "" || a.Key != "" || a.InstallationID != ""
+}
+
+// Whether the host is accessed with a GitHub App installation token, which
+// can't be set with `pro auth`
+func (h remoteHost) usesAppToken() bool {
+	return h.Type == "github" && githubApp.configured()
+}
+
+// Returned when app ID, private key or installation ID is missing
+var errAppCredentials = errors.New("GitHub App needs --app-id, --app-key and --app-installation-id to create a token")
+
+// GitHub rejected the app credentials when creating an installation token
+type appTokenError struct {
+	err error
+}
+
+func (e appTokenError) Error() string {
+	return "unable to create GitHub App installation token: " + e.err.Error()
+}
+
+func (e appTokenError) Unwrap() error {
+	return e.err
+}
+
+// Private key file of the GitHub App can't be read
+type appKeyError struct {
+	err error
+}
+
+func (e appKeyError) Error() string {
+	return "unable to read GitHub App private key: " + e.err.Error()
+}
+
+func (e appKeyError) Unwrap() error {
+	return e.err
+}
+
+// Replace token of GitHub hosts with the GitHub App installation token
+func withAppToken(host remoteHost) (remoteHost, error) {
+	if !host.usesAppToken() {
+		return host, nil
+	}
+
+	if githubApp.Token != "" {
+		host.Token = githubApp.Token
+		return host, nil
+	}
+
+	if token, ok := installationTokens[host.API]; ok {
+		host.Token = token
+		return host, nil
+	}
+
+	if githubApp.ID == "" || githubApp.Key == "" || githubApp.InstallationID == "" {
+		return host, errAppCredentials
+	}
+
+	key := []byte(githubApp.Key)
+	if !strings.HasPrefix(strings.TrimSpace(githubApp.Key), "-----BEGIN") {
+		var err error
+		key, err = ioutil.ReadFile(githubApp.Key)
+		if err != nil {
+			return host, appKeyError{err}
+		}
+	}
+
+	debug.Printf("Creating installation token of GitHub App %s for %s", githubApp.ID, host.API)
+
+	token, err := github.InstallationToken(host.API, githubApp.ID, key, githubApp.InstallationID)
+	if err != nil {
+		return host, appTokenError{err}
+	}
+
+	installationTokens[host.API] = token
+	host.Token = token
+
+	return host, nil
+}
+
+// Exit with a helpful message if the GitHub App installation token can't be
+// created. Other errors are left to the caller.
+func handleAppError(err error) {
+	var tokenErr appTokenError
+	var keyErr appKeyError
+
+	switch {
+	case errors.Is(err, errAppCredentials):
+		color.Red("GitHub App needs --app-id, --app-key and --app-installation-id to create a token.")
+		os.Exit(1)
+	case errors.As(err, &keyErr):
+		handleError(keyErr.err, "Unable to read GitHub App private key")
+	case errors.As(err, &tokenErr):
+		color.Red("Unable to create GitHub App installation token: %s", tokenErr.err.Error())
+		fmt.Println("Check the app ID, private key and installation ID.")
+		os.Exit(exitAuthError)
+	}
+}
+
+// What to do when the token was rejected
+func (h remoteHost) reauthHint() string {
+	if h.usesAppToken() {
+		return "GitHub App installation tokens expire after an hour, pass a new one."
+	}
+
+	return "Run `" + h.authCommand() + "` to connect again."
+}
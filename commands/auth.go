@@ -7,6 +7,9 @@ import (
 	"syscall"
 
 	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gitea"
 	"github.com/wowu/pro/providers/github"
 	"github.com/wowu/pro/providers/gitlab"
 
@@ -14,22 +17,50 @@ import (
 	"golang.org/x/term"
 )
 
-func Auth(provider string) {
+type AuthOptions struct {
+	// Self-hosted instance. Empty host means public instance, e.g. gitlab.com or github.com.
+	Host string
+	// Paste personal access token instead of logging in with browser (GitHub only)
+	Token bool
+}
+
+// Ask for a token and save it
+func Auth(provider string, options AuthOptions) {
+	host := options.Host
+
 	switch provider {
 	case "gitlab":
-		authgitlab()
+		authgitlab(host)
 	case "github":
-		authgithub()
+		authgithub(host, options.Token)
+	case "bitbucket":
+		if host != "" {
+			color.Red("Self-hosted Bitbucket is not supported.")
+			os.Exit(1)
+		}
+		authbitbucket()
+	case "gitea":
+		authgitea(host)
+	case "azure":
+		if host != "" {
+			color.Red("Azure DevOps Server is not supported.")
+			os.Exit(1)
+		}
+		authazure()
 	default:
 		fmt.Println("unknown provider")
 		os.Exit(1)
 	}
 }
 
-func authgitlab() {
-	fmt.Println("Generate your token at " + color.BlueString("https://gitlab.com/-/profile/personal_access_tokens?name=pro+cli&scopes=read_api"))
+func authgitlab(host string) {
+	if host == "" {
+		host = "gitlab.com"
+	}
+
+	fmt.Println("Generate your token at " + color.BlueString("https://%s/-/profile/personal_access_tokens?name=pro+cli&scopes=read_api", host))
 	fmt.Println()
-	fmt.Println("The only required scope is 'read_api'")
+	fmt.Println("The only required scope is 'read_api'. Select 'api' instead to create merge requests with `pro create`.")
 	color.Yellow("It's recommended to leave \"Expiration date\" blank.")
 	fmt.Println()
 
@@ -47,10 +78,61 @@ func authgitlab() {
 	}
 
 	// Check if token is valid by fetching user info
-	_, err = gitlab.User(token)
+	apiURL := gitlab.DefaultBaseURL
+	if host != "gitlab.com" {
+		apiURL = authHostAPI(host, "gitlab")
+	}
+
+	_, err = gitlab.User(apiURL, token)
+	if err != nil {
+		switch err {
+		case gitlab.ErrUnauthorized, gitlab.ErrTokenExpired:
+			color.Red("Token is invalid. Try again")
+			os.Exit(1)
+		case gitlab.ErrInsufficientScope:
+			color.Red("Token doesn't have 'read_api' scope. Try again with a new token")
+			os.Exit(1)
+		default:
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	conf := config.Get()
+	if host == "gitlab.com" {
+		conf.GitLabToken = token
+	} else {
+		conf.SetHostToken(host, "gitlab", token)
+	}
+	saveConfig(conf)
+
+	color.Green("Saved.")
+}
+
+func authgithub(host string, pasteToken bool) {
+	if host == "" {
+		host = "github.com"
+	}
+
+	var token string
+
+	clientID := githubClientID()
+	if pasteToken || clientID == "" {
+		token = askGitHubToken(host)
+	} else {
+		token = githubDeviceLogin(host, clientID)
+	}
+
+	// Check if token is valid by fetching user info
+	apiURL := github.DefaultBaseURL
+	if host != "github.com" {
+		apiURL = authHostAPI(host, "github")
+	}
+
+	_, err := github.User(apiURL, token)
 	if err != nil {
 		switch err {
-		case gitlab.ErrUnauthorized:
+		case github.ErrUnauthorized:
 			color.Red("Token is invalid. Try again")
 			os.Exit(1)
 		default:
@@ -60,14 +142,28 @@ func authgitlab() {
 	}
 
 	conf := config.Get()
-	conf.GitLabToken = token
-	config.Save(conf)
+	if host == "github.com" {
+		conf.GitHubToken = token
+	} else {
+		conf.SetHostToken(host, "github", token)
+	}
+	saveConfig(conf)
 
 	color.Green("Saved.")
 }
 
-func authgithub() {
-	fmt.Println("Generate personal access token at " + color.BlueString("https://github.com/settings/tokens/new?description=pro+cli&scopes=repo"))
+// OAuth app client ID, can be overridden for GitHub Enterprise instances with their own OAuth app
+func githubClientID() string {
+	if clientID := os.Getenv("PRO_GITHUB_CLIENT_ID"); clientID != "" {
+		return clientID
+	}
+
+	return github.OAuthClientID
+}
+
+// Ask user to paste personal access token
+func askGitHubToken(host string) string {
+	fmt.Println("Generate personal access token at " + color.BlueString("https://%s/settings/tokens/new?description=pro+cli&scopes=repo", host))
 	fmt.Println()
 	fmt.Println("The only required scope is 'repo'")
 	color.Yellow("It's recommended to set expiration to \"No expiration\"")
@@ -86,11 +182,117 @@ func authgithub() {
 		os.Exit(1)
 	}
 
+	return token
+}
+
+// Log in with OAuth device flow: user enters a one-time code in the browser
+func githubDeviceLogin(host string, clientID string) string {
+	code, err := github.RequestDeviceCode(host, clientID, "repo")
+	handleError(err, "Unable to start login")
+
+	fmt.Println("First copy your one-time code: " + color.YellowString(code.UserCode))
+	readLine("Press Enter to open " + color.BlueString(code.VerificationURI) + " in your browser...")
+	openBrowser(code.VerificationURI, "")
+
+	fmt.Println("Waiting for authorization...")
+
+	token, err := github.WaitForAccessToken(host, clientID, code)
+	if err != nil {
+		switch err {
+		case github.ErrAccessDenied:
+			color.Red("Authorization was denied. Try again")
+		case github.ErrDeviceCodeExpired:
+			color.Red("The code has expired. Try again")
+		default:
+			color.Red("Unable to log in: %s", err)
+		}
+
+		fmt.Println("Use `pro auth github --token` to paste a personal access token instead.")
+		os.Exit(1)
+	}
+
+	return token
+}
+
+func authbitbucket() {
+	fmt.Println("Create app password at " + color.BlueString("https://bitbucket.org/account/settings/app-passwords/new"))
+	fmt.Println()
+	fmt.Println("The only required permission is 'Pull requests: Read'")
+	fmt.Println()
+
+	// Ask for username and app password
+	username := readLine("Username: ")
+
+	fmt.Print("App password: ")
+	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	handleError(err, "Error while reading app password")
+
+	password := strings.TrimSpace(string(bytePassword))
+
+	if username == "" || password == "" {
+		color.Red("Username or app password is empty. Try again")
+		os.Exit(1)
+	}
+
+	token := username + ":" + password
+
+	// Check if app password is valid by fetching user info
+	_, err = bitbucket.User(token)
+	if err != nil {
+		switch err {
+		case bitbucket.ErrUnauthorized:
+			color.Red("Username or app password is invalid. Try again")
+			os.Exit(1)
+		default:
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	conf := config.Get()
+	conf.BitbucketToken = token
+	saveConfig(conf)
+
+	color.Green("Saved.")
+}
+
+func authgitea(host string) {
+	if host == "" {
+		host = readLine("Gitea host (e.g. gitea.example.com): ")
+	}
+
+	// Accept full URL as well as bare host name
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"), "/")
+
+	if host == "" {
+		color.Red("Host is empty. Try again")
+		os.Exit(1)
+	}
+
+	fmt.Println("Generate access token at " + color.BlueString("https://%s/user/settings/applications", host))
+	fmt.Println()
+	fmt.Println("The only required permission is read access to repositories")
+	fmt.Println()
+
+	// Ask for token
+	fmt.Print("Token: ")
+	byteToken, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	handleError(err, "Error while reading token")
+
+	token := strings.TrimSpace(string(byteToken))
+
+	if token == "" {
+		color.Red("Token is empty. Try again")
+		os.Exit(1)
+	}
+
 	// Check if token is valid by fetching user info
-	_, err = github.User(token)
+	_, err = gitea.User(authHostAPI(host, "gitea"), token)
 	if err != nil {
 		switch err {
-		case github.ErrUnauthorized:
+		case gitea.ErrUnauthorized:
 			color.Red("Token is invalid. Try again")
 			os.Exit(1)
 		default:
@@ -100,8 +302,57 @@ func authgithub() {
 	}
 
 	conf := config.Get()
-	conf.GitHubToken = token
-	config.Save(conf)
+	conf.SetHostToken(host, "gitea", token)
+	saveConfig(conf)
 
 	color.Green("Saved.")
 }
+
+func authazure() {
+	fmt.Println("Generate personal access token in your organization at " + color.BlueString("https://dev.azure.com/<organization>/_usersSettings/tokens"))
+	fmt.Println()
+	fmt.Println("The only required scope is 'Code (Read)'")
+	fmt.Println()
+
+	// Ask for token
+	fmt.Print("Token: ")
+	byteToken, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	handleError(err, "Error while reading token")
+
+	token := strings.TrimSpace(string(byteToken))
+
+	if token == "" {
+		color.Red("Token is empty. Try again")
+		os.Exit(1)
+	}
+
+	// Check if token is valid by fetching user profile
+	_, err = azure.User(token)
+	if err != nil {
+		switch err {
+		case azure.ErrUnauthorized:
+			color.Red("Token is invalid. Try again")
+			os.Exit(1)
+		default:
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	conf := config.Get()
+	conf.AzureToken = token
+	saveConfig(conf)
+
+	color.Green("Saved.")
+}
+
+// API base URL of a self-hosted instance, using configured API URL if the host is already known
+func authHostAPI(host string, hostType string) string {
+	hostConfig, ok := config.Get().FindHost(host)
+	if !ok || hostConfig.Type != hostType {
+		hostConfig = config.Host{Host: host, Type: hostType}
+	}
+
+	return hostAPI(hostConfig)
+}
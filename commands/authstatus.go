@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+// Public hosts with tokens stored at the top level of the config
+var publicHosts = []string{"github.com", "gitlab.com", "bitbucket.org", "dev.azure.com"}
+
+// Print whether a token is set for each public and configured host, and
+// whether it's valid. Exits with exitAuthError if any token is invalid.
+func AuthStatus() {
+	hostNames := append([]string{}, publicHosts...)
+	for _, hostConfig := range config.Get().Hosts {
+		hostNames = append(hostNames, hostConfig.Host)
+	}
+
+	invalid := false
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "HOST\tPROVIDER\tTOKEN\tVALID\tUSER")
+
+	for _, hostName := range hostNames {
+		host, ok := lookupHost(hostName)
+		if !ok || host.Type == "sourcehut" || host.Type == "custom" {
+			continue
+		}
+
+		if host.Token == "" {
+			fmt.Fprintf(writer, "%s\t%s\tno\t%s\t\n", host.Name, host.Type, color.YellowString("-"))
+			continue
+		}
+
+		// Gerrit has no endpoint to check the token with
+		if host.Type == "gerrit" {
+			fmt.Fprintf(writer, "%s\t%s\tyes\t%s\t\n", host.Name, host.Type, color.YellowString("-"))
+			continue
+		}
+
+		user, _, err := authenticatedUser(host)
+		fmt.Fprintf(writer, "%s\t%s\tyes\t%s\t%s\n", host.Name, host.Type, tokenValidity(err), user)
+
+		if err != nil {
+			invalid = true
+		}
+	}
+
+	writer.Flush()
+
+	if invalid {
+		os.Exit(exitAuthError)
+	}
+}
+
+func tokenValidity(err error) string {
+	switch {
+	case err == nil:
+		return color.GreenString("yes")
+	case errors.Is(err, gitlab.ErrTokenExpired):
+		return color.RedString("expired")
+	case isUnauthorizedError(err):
+		return color.RedString("no")
+	default:
+		return color.RedString("error: %s", err)
+	}
+}
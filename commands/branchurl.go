@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"net/url"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type BranchURLOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Branch to open, current branch when empty
+	Branch string
+	OutputOptions
+}
+
+// Open source tree of a branch on the remote, without looking up pull requests
+func BranchURL(repoPath string, options BranchURLOptions) {
+	repository := openRepo(repoPath)
+
+	remoteName := options.Remote
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	host, projectPath := resolveRemoteHost(repository, remoteName)
+
+	branch := options.Branch
+	if branch == "" {
+		branch = currentBranch(repository)
+	}
+
+	_, err := repository.Reference(plumbing.NewRemoteReferenceName(remoteName, branch), true)
+	if err != nil {
+		color.Yellow("Branch \"%s\" is not pushed to %s yet, the link won't work until it is.", branch, remoteName)
+	}
+
+	showURL(treeURL(host, projectPath, branch), options.OutputOptions)
+}
+
+// Web URL of the branch source tree
+func treeURL(host remoteHost, projectPath string, branch string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		return home + "/-/tree/" + providers.EscapePath(branch)
+	case "bitbucket":
+		return home + "/src/" + providers.EscapePath(branch)
+	case "gitea":
+		return home + "/src/branch/" + providers.EscapePath(branch)
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return repository.WebURL() + "?version=GB" + url.QueryEscape(branch)
+	default:
+		return home + "/tree/" + providers.EscapePath(branch)
+	}
+}
@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/wowu/pro/config"
+)
+
+// Open URL in browser. Browser command is taken from (in order of precedence):
+// browser argument, "browser" config key, $BROWSER and system default.
+func openBrowser(url string, browser string) {
+	var err error
+
+	if browser == "" {
+		browser = config.Get().Browser
+	}
+
+	if browser != "" {
+		err = runBrowserCommand(browser, url)
+		if err != nil {
+			fmt.Printf("Unable to open browser: %s\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if os.Getenv("BROWSER") != "" {
+		err = openEnvBrowser(os.Getenv("BROWSER"), url)
+		if err == nil {
+			return
+		}
+
+		fmt.Printf("Unable to open $BROWSER: %s\n", err)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if isWSL() {
+			err = openWSLBrowser(url)
+		} else {
+			err = exec.Command("xdg-open", url).Start()
+		}
+	case "windows":
+		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		err = exec.Command("open", url).Start()
+	default:
+		err = fmt.Errorf("unsupported platform")
+	}
+
+	if err != nil {
+		fmt.Printf("Unable to open browser: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Command opening URLs in the default browser, empty if none is available
+func systemBrowser() string {
+	switch runtime.GOOS {
+	case "linux":
+		if isWSL() && commandExists("wslview") {
+			return "wslview"
+		} else if isWSL() {
+			return "rundll32.exe"
+		}
+
+		if commandExists("xdg-open") {
+			return "xdg-open"
+		}
+	case "windows":
+		return "rundll32"
+	case "darwin":
+		return "open"
+	}
+
+	return ""
+}
+
+// Check if running under Windows Subsystem for Linux
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+
+	version, err := ioutil.ReadFile("/proc/version")
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(string(version)), "microsoft")
+}
+
+// Open URL in the Windows host browser
+func openWSLBrowser(url string) error {
+	if commandExists("wslview") {
+		return exec.Command("wslview", url).Start()
+	}
+
+	// Windows executables are available in WSL through interop. Unlike
+	// `cmd.exe /c start`, rundll32 doesn't need "&" in URLs to be escaped.
+	return exec.Command("rundll32.exe", "url.dll,FileProtocolHandler", url).Start()
+}
+
+// Open URL with browser command from $BROWSER. The variable can contain a list of
+// commands separated by ":" which are tried in order. "%s" in a command is
+// replaced with the URL, otherwise URL is passed as the last argument.
+func openEnvBrowser(browsers string, url string) error {
+	var err error
+
+	for _, browser := range filepath.SplitList(browsers) {
+		args := strings.Fields(browser)
+		if len(args) == 0 {
+			continue
+		}
+
+		if strings.Contains(browser, "%s") {
+			for i := range args {
+				args[i] = strings.ReplaceAll(args[i], "%s", url)
+			}
+		} else {
+			args = append(args, url)
+		}
+
+		err = exec.Command(args[0], args[1:]...).Start()
+		if err == nil {
+			return nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no browser command found")
+	}
+
+	return err
+}
+
+// Run browser command template. "{url}" placeholder is replaced with the URL,
+// otherwise URL is passed as the last argument. Arguments can be quoted, e.g.
+// open -a "Google Chrome" {url} --args --profile-directory="Profile 1"
+func runBrowserCommand(template string, url string) error {
+	args, err := splitCommand(template)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New("browser command is empty")
+	}
+
+	if strings.Contains(template, "{url}") {
+		for i := range args {
+			args[i] = strings.ReplaceAll(args[i], "{url}", url)
+		}
+	} else {
+		args = append(args, url)
+	}
+
+	return exec.Command(args[0], args[1:]...).Start()
+}
+
+// Split command line into arguments, respecting single and double quotes
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in browser command")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// Browser which can open a private window
+type privateBrowser struct {
+	// Matched against the configured browser command
+	keyword        string
+	flag           string
+	macApp         string
+	windowsCommand string
+	linuxCommands  []string
+}
+
+var privateBrowsers = []privateBrowser{
+	{"chrome", "--incognito", "Google Chrome", "chrome", []string{"google-chrome", "google-chrome-stable"}},
+	{"chromium", "--incognito", "Chromium", "chromium", []string{"chromium", "chromium-browser"}},
+	{"brave", "--incognito", "Brave Browser", "brave", []string{"brave-browser", "brave"}},
+	{"edge", "--inprivate", "Microsoft Edge", "msedge", []string{"microsoft-edge", "microsoft-edge-stable"}},
+	{"firefox", "--private-window", "Firefox", "firefox", []string{"firefox"}},
+}
+
+// Open URL in a private window. The configured browser is used if it supports
+// private windows, otherwise the first one installed. On Windows Edge is used
+// by default, as it's always available.
+func openPrivateBrowser(url string, browser string) {
+	if browser == "" {
+		browser = config.Get().Browser
+	}
+	if browser == "" {
+		browser = os.Getenv("BROWSER")
+	}
+
+	if browser == "" && (runtime.GOOS == "windows" || isWSL()) {
+		browser = "msedge"
+	}
+
+	candidates := privateBrowsers
+	for _, candidate := range privateBrowsers {
+		if strings.Contains(strings.ToLower(browser), candidate.keyword) {
+			candidates = []privateBrowser{candidate}
+			break
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate.open(url) == nil {
+			return
+		}
+	}
+
+	fmt.Println("Unable to open private window, supported browsers are Chrome, Chromium, Brave, Edge and Firefox.")
+	os.Exit(1)
+}
+
+func (b privateBrowser) open(url string) error {
+	switch {
+	case runtime.GOOS == "darwin":
+		// -R only reveals the app, failing if it's not installed
+		if err := exec.Command("open", "-Ra", b.macApp).Run(); err != nil {
+			return err
+		}
+
+		return exec.Command("open", "-na", b.macApp, "--args", b.flag, url).Start()
+	case runtime.GOOS == "windows" || isWSL():
+		// "start" resolves browsers registered in App Paths, "&" has to be escaped for cmd
+		return exec.Command("cmd.exe", "/c", "start", "", b.windowsCommand, b.flag, strings.ReplaceAll(url, "&", "^&")).Start()
+	default:
+		for _, command := range b.linuxCommands {
+			if commandExists(command) {
+				return exec.Command(command, b.flag, url).Start()
+			}
+		}
+
+		return errors.New(b.keyword + " is not installed")
+	}
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Pull request lookup cache for a single repository and branch
+type pullRequestCache struct {
+	key     string
+	headSHA string
+	ttl     time.Duration
+}
+
+// Create cache for given remote URL and branch. Returns false if caching is
+// disabled or the branch commit can't be resolved locally, since cache entries
+// are invalidated when the branch moves.
+func newPullRequestCache(repository *git.Repository, remoteURL string, branch string) (pullRequestCache, bool) {
+	ttl := config.Get().CacheDuration()
+	if ttl == 0 {
+		return pullRequestCache{}, false
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(branch))
+	if err != nil {
+		return pullRequestCache{}, false
+	}
+
+	return pullRequestCache{
+		key:     remoteURL + "#" + branch,
+		headSHA: hash.String(),
+		ttl:     ttl,
+	}, true
+}
+
+// Get cached pull request. Returns false on cache miss.
+func (c pullRequestCache) get() (pullRequestInfo, bool) {
+	entry, ok := config.GetCache(c.key, c.headSHA, c.ttl)
+	if !ok {
+		return pullRequestInfo{}, false
+	}
+
+	return pullRequestInfo{Number: entry.Number, Title: entry.Title, URL: entry.URL, Draft: entry.Draft}, true
+}
+
+func (c pullRequestCache) set(pullRequest pullRequestInfo) {
+	config.SetCache(c.key, config.CacheEntry{
+		URL:     pullRequest.URL,
+		Number:  pullRequest.Number,
+		Title:   pullRequest.Title,
+		Draft:   pullRequest.Draft,
+		HeadSHA: c.headSHA,
+	}, c.ttl)
+}
+
+// Parse remote URL like parseRemoteURL, reusing the result stored for the
+// repository until the URL, SSH config or parsing rules change. Resolving SSH
+// host aliases parses SSH config files, which adds up when pro is bound to a
+// frequently used key.
+func parseCachedRemoteURL(repository *git.Repository, remoteName string, remoteURL string, useCache bool) (string, string, error) {
+	worktree, err := repository.Worktree()
+	if !useCache || config.Get().CacheDuration() == 0 || err != nil {
+		return parseRemoteURL(remoteURL)
+	}
+
+	root := worktree.Filesystem.Root()
+	key := root + "#" + remoteName
+	sshConfig := sshConfigVersion()
+
+	entry, ok := config.GetProject(key)
+	if ok && entry.RemoteURL == remoteURL && entry.SSHConfig == sshConfig && entry.Version == remoteURLParserVersion {
+		debug.Printf("Using cached host %s and path %s of remote %s", entry.Host, entry.Path, remoteName)
+		return entry.Host, entry.Path, nil
+	}
+
+	hostName, projectPath, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	config.SetProject(key, config.ProjectEntry{
+		Root:      root,
+		RemoteURL: remoteURL,
+		Host:      hostName,
+		Path:      projectPath,
+		SSHConfig: sshConfig,
+		Version:   remoteURLParserVersion,
+	})
+
+	return hostName, projectPath, nil
+}
+
+// Modification times of the user and system SSH config files and files they
+// include, changing when host aliases may resolve differently
+func sshConfigVersion() string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, sshConfigFiles(filepath.Join(home, ".ssh", "config"), filepath.Join(home, ".ssh"), 0)...)
+	}
+	files = append(files, sshConfigFiles("/etc/ssh/ssh_config", "/etc/ssh", 0)...)
+
+	var times []string
+	for _, file := range files {
+		var modTime int64
+		if info, err := os.Stat(file); err == nil {
+			modTime = info.ModTime().UnixNano()
+		}
+
+		times = append(times, file+"="+strconv.FormatInt(modTime, 10))
+	}
+
+	return strings.Join(times, ",")
+}
+
+// Maximum depth of nested Include directives, same as in OpenSSH
+const maxSSHIncludeDepth = 16
+
+// SSH config file followed by files it includes. Relative Include paths are
+// resolved against dir, which is ~/.ssh for the user config and /etc/ssh for
+// the system one. Matched file names are part of the result, so adding a file
+// matching an Include pattern changes the version too.
+func sshConfigFiles(file string, dir string, depth int) []string {
+	files := []string{file}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil || depth >= maxSSHIncludeDepth {
+		return files
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		keyword, args := line, ""
+		if i := strings.IndexAny(line, " \t="); i >= 0 {
+			keyword, args = line[:i], strings.TrimLeft(line[i:], " \t=")
+		}
+
+		if !strings.EqualFold(keyword, "include") {
+			continue
+		}
+
+		for _, pattern := range strings.Fields(args) {
+			if strings.HasPrefix(pattern, "~/") {
+				if home, err := os.UserHomeDir(); err == nil {
+					pattern = filepath.Join(home, pattern[2:])
+				}
+			} else if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+
+			matches, _ := filepath.Glob(pattern)
+			for _, match := range matches {
+				files = append(files, sshConfigFiles(match, dir, depth+1)...)
+			}
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSSHConfigFilesFollowsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	config := write("config", "Include config.d/*\nHost work\n  include=extra\n")
+	work := write("config.d/work", "Include "+filepath.Join(dir, "nested")+"\n")
+	nested := write("nested", "Host nested\n  HostName example.com\n")
+	extra := filepath.Join(dir, "extra")
+
+	got := sshConfigFiles(config, dir, 0)
+	want := []string{config, work, nested}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Files matching Include patterns later change the result
+	write("extra", "")
+	got = sshConfigFiles(config, dir, 0)
+	want = []string{config, work, nested, extra}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSSHConfigFilesStopsAtIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	if err := os.WriteFile(config, []byte("Include config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(sshConfigFiles(config, dir, 0)); got != maxSSHIncludeDepth+1 {
+		t.Errorf("got %d files, want %d", got, maxSSHIncludeDepth+1)
+	}
+}
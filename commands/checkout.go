@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+type CheckoutOptions struct {
+	// Name of the remote the PR is opened against, default remote when empty
+	Remote string
+}
+
+// Fetch branch of the pull request with given number and switch to it
+func Checkout(repoPath string, number int, options CheckoutOptions) {
+	repository := openRepo(repoPath)
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		color.Red("Unable to check out a pull request in a bare repository.")
+		os.Exit(1)
+	}
+
+	remoteName := options.Remote
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	remoteURL := preferredURL(repository, getRemote(repository, remoteName))
+
+	host, projectPath := resolveRemoteURL(remoteURL)
+
+	if host.Type == "sourcehut" {
+		fmt.Println("SourceHut uses patches sent by email instead of pull requests.")
+		os.Exit(1)
+	}
+
+	if host.Token == "" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	pullRequest, err := getPullRequest(host, projectPath, number)
+	if isNotFoundError(err) {
+		color.Red("Pull request #%d not found in %s.", number, projectPath)
+		os.Exit(exitNoPullRequest)
+	}
+	handleProviderError(host, err, "Unable to get pull request")
+
+	if pullRequest.HeadBranch == "" {
+		color.Red("Unable to find the branch of pull request #%d.", number)
+		os.Exit(1)
+	}
+
+	fetchURL, fetchRef := pullRequestRef(host, remoteURL, pullRequest)
+	localRef := plumbing.ReferenceName(fmt.Sprintf("refs/pro/pull/%d", number))
+
+	fmt.Printf("Fetching %s from %s\n", color.GreenString(pullRequest.HeadBranch), fetchURL)
+
+	installHTTPClient()
+
+	remote := git.NewRemote(repository.Storer, &gitconfig.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{fetchURL},
+	})
+	err = remote.Fetch(&git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:%s", fetchRef, localRef))},
+		Auth:     fetchAuth(host, fetchURL),
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		color.Red("Unable to fetch pull request #%d: %s", number, err)
+		os.Exit(1)
+	}
+
+	fetched, err := repository.Reference(localRef, true)
+	handleError(err, "Unable to read fetched branch")
+
+	branch := plumbing.NewBranchReferenceName(pullRequest.HeadBranch)
+	updateBranch(repository, branch, fetched.Hash())
+
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: branch})
+	if errors.Is(err, git.ErrUnstagedChanges) {
+		color.Red("You have uncommitted changes. Commit or stash them before checking out pull request #%d.", number)
+		os.Exit(1)
+	}
+	handleError(err, "Unable to switch branch")
+
+	fmt.Printf("Switched to branch %s\n", color.GreenString(pullRequest.HeadBranch))
+}
+
+// URL and ref to fetch the pull request from. GitHub, Gitea and GitLab expose
+// PRs as refs of the target repository, which also works for forks. Other
+// providers need the branch fetched from the source repository.
+func pullRequestRef(host remoteHost, remoteURL string, pullRequest pullRequestInfo) (string, string) {
+	switch host.Type {
+	case "github", "gitea":
+		return remoteURL, fmt.Sprintf("refs/pull/%d/head", pullRequest.Number)
+	case "gitlab":
+		return remoteURL, fmt.Sprintf("refs/merge-requests/%d/head", pullRequest.Number)
+	}
+
+	if pullRequest.HeadCloneURL != "" {
+		return pullRequest.HeadCloneURL, plumbing.NewBranchReferenceName(pullRequest.HeadBranch).String()
+	}
+
+	return remoteURL, plumbing.NewBranchReferenceName(pullRequest.HeadBranch).String()
+}
+
+// Authenticate HTTPS fetches with the stored token. SSH remotes use the SSH agent.
+func fetchAuth(host remoteHost, url string) transport.AuthMethod {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil
+	}
+
+	switch host.Type {
+	case "github":
+		return &githttp.BasicAuth{Username: "x-access-token", Password: host.Token}
+	case "gitlab":
+		return &githttp.BasicAuth{Username: "oauth2", Password: host.Token}
+	case "bitbucket":
+		username, password, _ := strings.Cut(host.Token, ":")
+		return &githttp.BasicAuth{Username: username, Password: password}
+	case "gitea":
+		return &githttp.BasicAuth{Username: host.Token, Password: host.Token}
+	case "azure":
+		return &githttp.BasicAuth{Username: "pro", Password: host.Token}
+	default:
+		return nil
+	}
+}
+
+// Fetch over HTTPS through the same proxy as API requests. The API timeout
+// is left out, as fetching a big branch can take longer.
+func installHTTPClient() {
+	httpClient := githttp.NewClient(&http.Client{Transport: providers.Client().Transport})
+	client.InstallProtocol("https", httpClient)
+	client.InstallProtocol("http", httpClient)
+}
+
+// Point local branch at the fetched commit. Existing branches are only
+// fast-forwarded, so local commits are never lost.
+func updateBranch(repository *git.Repository, branch plumbing.ReferenceName, hash plumbing.Hash) {
+	existing, err := repository.Reference(branch, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		err = repository.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+		handleError(err, "Unable to create branch")
+		return
+	}
+	handleError(err, "Unable to read branch")
+
+	if existing.Hash() == hash {
+		return
+	}
+
+	head, err := repository.Head()
+	if err == nil && head.Name() == branch {
+		color.Yellow("Branch %s is checked out and differs from the pull request, pull to update it.", branch.Short())
+		return
+	}
+
+	local, err := repository.CommitObject(existing.Hash())
+	handleError(err, "Unable to read branch")
+
+	fetched, err := repository.CommitObject(hash)
+	handleError(err, "Unable to read fetched branch")
+
+	isAncestor, err := local.IsAncestor(fetched)
+	handleError(err, "Unable to compare branches")
+
+	if !isAncestor {
+		color.Red("Branch %s has diverged from the pull request.", branch.Short())
+		fmt.Println("Rename or delete the local branch and try again.")
+		os.Exit(1)
+	}
+
+	err = repository.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+	handleError(err, "Unable to update branch")
+}
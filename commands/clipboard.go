@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Copy text to system clipboard using platform clipboard utility
+func copyToClipboard(text string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	case "windows":
+		cmd = exec.Command("clip")
+	case "linux":
+		if os.Getenv("WAYLAND_DISPLAY") != "" && commandExists("wl-copy") {
+			cmd = exec.Command("wl-copy")
+		} else if commandExists("xclip") {
+			cmd = exec.Command("xclip", "-selection", "clipboard")
+		} else if commandExists("xsel") {
+			cmd = exec.Command("xsel", "--clipboard", "--input")
+		} else {
+			return fmt.Errorf("no clipboard utility found, install xclip, xsel or wl-clipboard")
+		}
+	default:
+		return fmt.Errorf("unsupported platform")
+	}
+
+	cmd.Stdin = strings.NewReader(text)
+
+	return cmd.Run()
+}
+
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
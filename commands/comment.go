@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type CommentOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Comment text, "-" reads it from stdin
+	Body string
+	// File to read the comment from, used instead of Body
+	File string
+}
+
+// Post a comment to the current branch's pull request
+func Comment(repoPath string, options CommentOptions) {
+	body := commentBody(options)
+
+	project := resolveProject(repoPath, options.Remote)
+	host := project.host
+
+	if host.Type != "github" && host.Type != "gitlab" {
+		color.Red("Commenting is not supported for %s yet.", host.Name)
+		os.Exit(1)
+	}
+
+	pullRequest, ok := selectPullRequest(findPullRequests(host, project.branch, project.path, ""), false)
+	if !ok {
+		fmt.Println("No open pull request found for current branch")
+		os.Exit(exitNoPullRequest)
+	}
+
+	var commentURL string
+
+	switch host.Type {
+	case "github":
+		comment, err := github.CreateComment(host.API, project.path, host.Token, pullRequest.Number, body)
+		handleCommentError(host, err)
+
+		commentURL = comment.HtmlURL
+	case "gitlab":
+		note, err := gitlab.CreateComment(host.API, project.path, host.Token, pullRequest.Number, body)
+		handleCommentError(host, err)
+
+		commentURL = fmt.Sprintf("%s#note_%d", pullRequest.URL, note.ID)
+	}
+
+	color.Green("Commented on #%d: %s", pullRequest.Number, pullRequest.Title)
+	fmt.Fprintln(results(), commentURL)
+}
+
+// Read comment from the file, stdin or the argument. Exits if it's empty.
+func commentBody(options CommentOptions) string {
+	body := options.Body
+
+	switch {
+	case options.File != "":
+		data, err := ioutil.ReadFile(options.File)
+		handleError(err, "Unable to read comment file")
+		body = string(data)
+	case options.Body == "-":
+		data, err := ioutil.ReadAll(os.Stdin)
+		handleError(err, "Unable to read comment from stdin")
+		body = string(data)
+	}
+
+	if strings.TrimSpace(body) == "" {
+		color.Red("Comment is empty.")
+		os.Exit(1)
+	}
+
+	return body
+}
+
+func handleCommentError(host remoteHost, err error) {
+	if errors.Is(err, gitlab.ErrInsufficientScope) {
+		color.Red("Unable to comment: %s", err.Error())
+		fmt.Printf("Commenting requires a GitLab token with 'api' scope. Run `%s` to set a new token.\n", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	handleProviderError(host, err, "Unable to comment")
+}
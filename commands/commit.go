@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/wowu/pro/providers/azure"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type CommitOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	OutputOptions
+}
+
+// Open page of given commit, HEAD if revision is empty. Revision can be
+// anything git understands, e.g. a SHA, tag or "HEAD~1".
+func Commit(repoPath string, revision string, options CommitOptions) {
+	repository := openRepo(repoPath)
+
+	remoteName := options.Remote
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	host, projectPath := resolveRemoteHost(repository, remoteName)
+
+	var hash plumbing.Hash
+	if revision == "" {
+		hash = repositoryHead(repository).Hash()
+	} else {
+		resolved, err := repository.ResolveRevision(plumbing.Revision(revision))
+		if err != nil {
+			color.Red("Commit \"%s\" not found in the local repository.", revision)
+			os.Exit(1)
+		}
+
+		hash = *resolved
+	}
+
+	if !isPushed(repository, remoteName, hash) {
+		color.Yellow("Commit %s is not pushed to %s yet, the link won't work until it is.", hash.String()[:7], remoteName)
+	}
+
+	showURL(commitURL(host, projectPath, hash.String()), options.OutputOptions)
+}
+
+// Web URL of the commit
+func commitURL(host remoteHost, projectPath string, sha string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		return home + "/-/commit/" + sha
+	case "bitbucket":
+		return home + "/commits/" + sha
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return repository.WebURL() + "/commit/" + sha
+	default:
+		return home + "/commit/" + sha
+	}
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type CompareOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Branch to compare with, default branch of the remote when empty
+	Base string
+	OutputOptions
+}
+
+// Open comparison of the current branch with a base branch, e.g. to review the
+// diff before creating a pull request
+func Compare(repoPath string, options CompareOptions) {
+	repository := openRepo(repoPath)
+
+	remoteName := options.Remote
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	host, projectPath := resolveRemoteHost(repository, remoteName)
+
+	branch := currentBranch(repository)
+	printInfo("Current branch: %s\n", color.GreenString(branch))
+
+	base := options.Base
+	if base == "" {
+		base = compareBase(repository, remoteName, host, projectPath)
+		printInfo("Comparing with %s\n", color.GreenString(base))
+	}
+
+	if base == branch {
+		color.Yellow("Branch %s is the base branch, there is nothing to compare.", branch)
+	}
+
+	_, err := repository.Reference(plumbing.NewRemoteReferenceName(remoteName, branch), true)
+	if err != nil {
+		color.Yellow("Branch \"%s\" is not pushed to %s yet, the link won't work until it is.", branch, remoteName)
+	}
+
+	showURL(compareURL(host, projectPath, base, branch), options.OutputOptions)
+}
+
+// Default branch of the remote, or the first of main branches pushed to it.
+// Exits if none is found.
+func compareBase(repository *git.Repository, remoteName string, host remoteHost, projectPath string) string {
+	if defaultBranch := remoteDefaultBranch(repository, remoteName, host, projectPath); defaultBranch != "" {
+		return defaultBranch
+	}
+
+	mainBranches := config.Current().MainBranches
+	if len(mainBranches) == 0 {
+		mainBranches = config.DefaultMainBranches
+	}
+
+	for _, branch := range mainBranches {
+		if _, err := repository.Reference(plumbing.NewRemoteReferenceName(remoteName, branch), true); err == nil {
+			return branch
+		}
+	}
+
+	color.Red("Unable to find the default branch of %s.", remoteName)
+	fmt.Println("Pass the base branch, e.g. `pro compare main`.")
+	os.Exit(1)
+	return ""
+}
+
+// Web URL comparing branch with base
+func compareURL(host remoteHost, projectPath string, base string, branch string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "github", "gitea":
+		return home + "/compare/" + providers.EscapePath(base) + "..." + providers.EscapePath(branch)
+	case "gitlab":
+		return home + "/-/compare/" + providers.EscapePath(base) + "..." + providers.EscapePath(branch)
+	case "bitbucket":
+		return home + "/branches/compare/" + providers.EscapePath(branch) + "%0D" + providers.EscapePath(base)
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return repository.WebURL() + "/branchCompare?baseVersion=GB" + url.QueryEscape(base) + "&targetVersion=GB" + url.QueryEscape(branch)
+	default:
+		color.Red("Comparing branches is not supported for %s.", host.Name)
+		os.Exit(1)
+		return ""
+	}
+}
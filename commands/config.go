@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/wowu/pro/config"
+
+	"github.com/fatih/color"
+)
+
+// Print value of a config key
+func ConfigGet(key string) {
+	value, err := config.Get().GetKey(key)
+	handleError(err, "")
+
+	fmt.Println(value)
+}
+
+// Change value of a config key and save the config file
+func ConfigSet(key string, value string) {
+	conf := config.Get()
+
+	err := conf.SetKey(key, value)
+	handleError(err, "")
+
+	saveConfig(conf)
+
+	color.Green("Updated %s.", key)
+}
+
+// Print location of the config file
+func ConfigPath() {
+	fmt.Println(configPath())
+}
+
+// Location of the config file or exit
+func configPath() string {
+	path, err := config.Path()
+	handleError(err, "Unable to find config file")
+
+	return path
+}
+
+// Write config file or exit
+func saveConfig(conf config.Config) {
+	handleError(config.Save(conf), "Unable to save config file")
+}
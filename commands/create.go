@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type CreateOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// PR title, defaults to the last commit subject
+	Title string
+	Body  string
+	// Target branch, defaults to the repository default branch
+	Base string
+	// Open as a draft, not ready for review yet
+	Draft bool
+	OutputOptions
+}
+
+// Create pull request for the current branch
+func Create(repoPath string, options CreateOptions) {
+	project := resolveProject(repoPath, options.Remote)
+	host, branch, projectPath := project.host, project.branch, project.path
+
+	if options.Title == "" {
+		options.Title = headCommitSubject(project.repository)
+	}
+
+	var url string
+
+	switch host.Type {
+	case "github":
+		url = createGitHub(host, branch, projectPath, options)
+	case "gitlab":
+		url = createGitLab(host, branch, projectPath, options)
+	default:
+		color.Red("Creating pull requests is not supported for %s yet.", host.Name)
+		fmt.Println("Create pull request at", color.BlueString(newPullRequestURL(host, projectPath, branch)))
+		os.Exit(1)
+	}
+
+	showURL(url, options.OutputOptions)
+}
+
+func createGitHub(host remoteHost, branch string, projectPath string, options CreateOptions) string {
+	if host.Token == "" {
+		color.Red("GitHub token is not set. Run `%s` to set it.", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	base := options.Base
+	if base == "" {
+		repository, err := github.Repository(host.API, projectPath, host.Token)
+		handleGitHubCreateError(host, err)
+		base = repository.DefaultBranch
+	}
+
+	pullRequest, err := github.CreatePullRequest(host.API, projectPath, host.Token, github.NewPullRequest{
+		Title: options.Title,
+		Body:  options.Body,
+		Head:  branch,
+		Base:  base,
+		Draft: options.Draft,
+	})
+	handleGitHubCreateError(host, err)
+
+	color.Green("Created pull request #%d: %s", pullRequest.Number, pullRequest.Title)
+
+	return pullRequest.HtmlURL
+}
+
+func handleGitHubCreateError(host remoteHost, err error) {
+	if err == nil {
+		return
+	}
+
+	exitIfRateLimited(err)
+
+	color.Red("Unable to create pull request: %s", err.Error())
+
+	if errors.Is(err, github.ErrUnauthorized) {
+		fmt.Println("Token may be expired or deleted. " + host.reauthHint())
+		os.Exit(exitAuthError)
+	} else if errors.Is(err, github.ErrNotFound) {
+		fmt.Println("Make sure the repository exists and the token has 'repo' scope.")
+	}
+
+	os.Exit(1)
+}
+
+func createGitLab(host remoteHost, branch string, projectPath string, options CreateOptions) string {
+	if host.Token == "" {
+		color.Red("GitLab token is not set. Run `%s` to set it.", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	base := options.Base
+	if base == "" {
+		project, err := gitlab.Project(host.API, projectPath, host.Token)
+		handleGitLabCreateError(host, err)
+		base = project.DefaultBranch
+	}
+
+	// GitLab marks drafts with a title prefix
+	title := options.Title
+	if options.Draft && !gitlab.HasDraftPrefix(title) {
+		title = "Draft: " + title
+	}
+
+	mergeRequest, err := gitlab.CreateMergeRequest(host.API, projectPath, host.Token, gitlab.NewMergeRequest{
+		SourceBranch: branch,
+		TargetBranch: base,
+		Title:        title,
+		Description:  options.Body,
+	})
+	handleGitLabCreateError(host, err)
+
+	color.Green("Created merge request !%d: %s", mergeRequest.IID, mergeRequest.Title)
+
+	return mergeRequest.WebUrl
+}
+
+func handleGitLabCreateError(host remoteHost, err error) {
+	if err == nil {
+		return
+	}
+
+	color.Red("Unable to create merge request: %s", err.Error())
+
+	if errors.Is(err, gitlab.ErrUnauthorized) {
+		fmt.Printf("Connect GitLab again with `%s`.\n", host.authCommand())
+		os.Exit(exitAuthError)
+	} else if errors.Is(err, gitlab.ErrForbidden) {
+		fmt.Printf("Creating merge requests requires a token with 'api' scope. Run `%s` to set a new token.\n", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	os.Exit(1)
+}
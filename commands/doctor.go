@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers"
+
+	"github.com/fatih/color"
+)
+
+// Check the setup pro depends on and print the result of each check: git,
+// the config file, the browser, and tokens and reachability of each host.
+// Exits with 1 if any check failed.
+func Doctor() {
+	failed := false
+
+	check := func(ok bool, format string, a ...interface{}) {
+		status := color.GreenString("ok  ")
+		if !ok {
+			status = color.RedString("fail")
+			failed = true
+		}
+
+		fmt.Printf("[%s] %s\n", status, fmt.Sprintf(format, a...))
+	}
+
+	warn := func(format string, a ...interface{}) {
+		fmt.Printf("[%s] %s\n", color.YellowString("warn"), fmt.Sprintf(format, a...))
+	}
+
+	if path, err := exec.LookPath("git"); err == nil {
+		check(true, "git found at %s", path)
+	} else {
+		warn("git not found, it's needed by `pro checkout`")
+	}
+
+	configFile := configPath()
+
+	configErr := config.Check()
+	if configErr != nil {
+		check(false, "Config file %s: %s", configFile, configErr)
+		fmt.Println("Fix or remove the config file and run `pro doctor` again.")
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		warn("Config file %s doesn't exist yet, run `pro auth` to create it", configFile)
+	} else {
+		check(true, "Config file %s", configFile)
+	}
+
+	switch browser := config.Get().Browser; {
+	case browser != "":
+		_, err := splitCommand(browser)
+		check(err == nil, "Browser from config: %s", browser)
+	case os.Getenv("BROWSER") != "":
+		check(true, "Browser from $BROWSER: %s", os.Getenv("BROWSER"))
+	case systemBrowser() != "":
+		check(true, "Browser opened with %s", systemBrowser())
+	default:
+		check(false, "No browser opener found, set `browser` in the config or use --print")
+	}
+
+	hostNames := append([]string{}, publicHosts...)
+	for _, hostConfig := range config.Get().Hosts {
+		hostNames = append(hostNames, hostConfig.Host)
+	}
+
+	for _, hostName := range hostNames {
+		host, ok := lookupHost(hostName)
+		if !ok || host.Type == "sourcehut" || host.Type == "custom" {
+			continue
+		}
+
+		switch {
+		case host.Token == "" && !isReachable(host.API):
+			check(false, "%s: %s is not reachable", host.Name, host.API)
+		case host.Token == "":
+			warn("%s: token not set, run `%s` to set it", host.Name, host.authCommand())
+		case host.Type == "gerrit" && !isReachable(host.API):
+			check(false, "%s: %s is not reachable", host.Name, host.API)
+		case host.Type == "gerrit":
+			// Gerrit has no endpoint to check the token with
+			check(true, "%s: token set, %s reachable", host.Name, host.API)
+		default:
+			user, _, err := authenticatedUser(host)
+			if err != nil {
+				check(false, "%s: token check failed: %s", host.Name, err)
+			} else {
+				check(true, "%s: token valid, logged in as %s", host.Name, user)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Check if the API answers at all, any HTTP status means it's reachable
+func isReachable(apiURL string) bool {
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return true
+}
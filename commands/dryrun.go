@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+)
+
+// Print what the open command would do, resolving only what is available locally.
+// No API requests are made and nothing is opened.
+func dryRunOpen(repository *git.Repository, remoteName string, checkUpstream bool, options OpenOptions) {
+	color.Yellow("Dry run, no API requests are made and nothing is opened.")
+
+	remoteURL := preferredURL(repository, getRemote(repository, remoteName))
+	printPlan("Remote", fmt.Sprintf("%s (%s)", remoteName, remoteURL))
+
+	hostName, projectPath, err := parseRemoteURL(remoteURL)
+	handleError(err, "Unable to parse remote URL")
+
+	printPlan("Host", hostName)
+	printPlan("Project", projectPath)
+
+	host, ok := resolveHost(hostName)
+	if !ok {
+		printPlan("Provider", color.RedString("unknown, add the host to the config file"))
+		os.Exit(exitUnknownHost)
+	}
+
+	printPlan("Provider", host.Type)
+	if host.API != "" {
+		printPlan("API", host.API)
+		printPlan("Token", tokenState(host))
+	}
+
+	if options.Number != 0 {
+		printPlan("Lookup", fmt.Sprintf("pull request #%d", options.Number))
+		printPlan("Open", "pull request URL returned by the API")
+		return
+	}
+
+	branch := options.Branch
+	if branch == "" {
+		branch = currentBranch(repository)
+	}
+	printPlan("Branch", branch)
+
+	if pushed := pushedBranch(repository, branch); pushed != branch {
+		printPlan("Pushed as", pushed)
+		branch = pushed
+	}
+
+	if host.Type == "gerrit" {
+		printPlan("Lookup", "change with the Change-Id of HEAD commit")
+		printPlan("Open", "change URL returned by the API")
+		return
+	}
+
+	// Empty host keeps the default branch from being looked up in the API
+	if !options.ForcePR && isMainBranch(repository, remoteName, remoteHost{}, projectPath, branch) {
+		printPlan("Open", homeURL(hostName, projectPath)+" (main branch)")
+		return
+	}
+
+	if host.Type == "sourcehut" {
+		printPlan("Open", homeURL(hostName, projectPath)+"/log/"+providers.EscapePath(branch))
+		return
+	}
+
+	if host.Type == "custom" {
+		printPlan("Open", newPullRequestURL(host, projectPath, branch)+" (compare_url, no API lookup)")
+		return
+	}
+
+	printPlan("Lookup", "GET "+findPullRequestURL(host, projectPath, branch, options.Base))
+	if checkUpstream {
+		if _, err := repository.Remote("upstream"); err == nil {
+			printPlan("", "then the upstream remote if no pull request is found")
+		}
+	}
+
+	printPlan("Open", "pull request URL returned by the API")
+	printPlan("Create", newPullRequestURL(host, projectPath, branch)+" (no pull request found)")
+}
+
+// API request used to look up pull request for given branch
+func findPullRequestURL(host remoteHost, projectPath string, branch string, base string) string {
+	switch host.Type {
+	case "gitlab":
+		return gitlab.FindMergeRequestURL(host.API, projectPath, branch, base)
+	case "github":
+		return github.FindPullRequestURL(host.API, projectPath, branch, base)
+	case "bitbucket":
+		return bitbucket.FindPullRequestURL(projectPath, branch, base)
+	case "gitea":
+		return gitea.ListPullRequestsURL(host.API, projectPath)
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return azure.FindPullRequestURL(repository, branch, base)
+	default:
+		return ""
+	}
+}
+
+func tokenState(host remoteHost) string {
+	if host.Token == "" {
+		return color.YellowString("not set, run `%s`", host.authCommand())
+	}
+
+	return "set"
+}
+
+func printPlan(label string, value string) {
+	if label != "" {
+		label += ":"
+	}
+
+	fmt.Printf("%-10s %s\n", label, value)
+}
@@ -0,0 +1,11 @@
+package commands
+
+// Exit codes allowing scripts to tell failures apart, e.g. `pro -p || pro create`
+const (
+	// No open pull request exists for the branch
+	exitNoPullRequest = 3
+	// Token is missing, expired or revoked
+	exitAuthError = 4
+	// Remote host is not a known provider and not configured
+	exitUnknownHost = 5
+)
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/providers/gerrit"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+)
+
+// Gerrit reviews commits instead of branches, so the change is found by the
+// Change-Id footer of the HEAD commit
+func openGerritChange(stdout io.Writer, repository *git.Repository, host remoteHost, branch string, projectPath string, options OpenOptions) {
+	// Authenticated HTTP remotes have "/a" prefix
+	projectPath = strings.TrimPrefix(projectPath, "a/")
+
+	commit, err := repository.CommitObject(repositoryHead(repository).Hash())
+	handleError(err, "Unable to read HEAD commit")
+
+	changeID, ok := gerrit.ParseChangeID(commit.Message)
+	if !ok {
+		color.Red("HEAD commit has no Change-Id footer.")
+		fmt.Println("Install the commit-msg hook of your Gerrit instance and amend the commit to add one.")
+		os.Exit(exitNoPullRequest)
+	}
+
+	printInfo("Change-Id: %s\n", color.GreenString(changeID))
+
+	changes, err := gerrit.FindChanges(host.API, projectPath, host.Token, changeID)
+	if errors.Is(err, gerrit.ErrNotFound) {
+		fmt.Println("No change found for HEAD commit")
+		fmt.Println("Push it for review with", color.BlueString("git push origin HEAD:refs/for/<branch>"))
+		os.Exit(exitNoPullRequest)
+	}
+	handleProviderError(host, err, "Unable to get changes")
+
+	// The same Change-Id is used for cherry-picks to other branches
+	var pullRequests []pullRequestInfo
+	for _, change := range changes {
+		if options.Base != "" && change.Branch != options.Base {
+			continue
+		}
+
+		pullRequests = append(pullRequests, pullRequestInfo{
+			Number: change.Number,
+			Title:  change.Subject,
+			URL:    change.URL(host.API),
+			Draft:  change.WorkInProgress,
+		})
+	}
+
+	pullRequest, found := selectPullRequest(pullRequests, options.First || options.JSON)
+	if !found {
+		color.Red("No change targeting %s found for HEAD commit.", options.Base)
+		os.Exit(exitNoPullRequest)
+	}
+
+	showResult(stdout, Result{
+		Branch:   branch,
+		URL:      pullRequest.URL,
+		Provider: host.Type,
+		Number:   pullRequest.Number,
+		Title:    pullRequest.Title,
+		Draft:    pullRequest.Draft,
+	}, options)
+}
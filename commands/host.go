@@ -0,0 +1,329 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+// Git hosting instance resolved from the remote host name
+type remoteHost struct {
+	Name  string // host name, e.g. github.com
+	Type  string // provider type, "github", "gitlab", "bitbucket", "gitea", "azure", "gerrit", "sourcehut" or "custom"
+	API   string // API base URL
+	Token string
+}
+
+// Command that stores a token for this host
+func (h remoteHost) authCommand() string {
+	if h.Name == "github.com" || h.Name == "gitlab.com" || h.Name == "bitbucket.org" || azure.IsHost(h.Name) {
+		return "pro auth " + h.Type
+	}
+
+	return "pro auth " + h.Type + " --host " + h.Name
+}
+
+// Determine provider type, API base URL and token for given remote host.
+// Self-hosted instances are looked up in the config file.
+func resolveHost(name string) (remoteHost, bool) {
+	host, ok, warning, err := resolveHostWithWarning(name)
+	handleAppError(err)
+
+	if warning != "" {
+		color.Yellow("%s", warning)
+	}
+
+	return host, ok
+}
+
+// Same as resolveHost, returning the warning about .pro.yml settings instead
+// of printing it and GitHub App errors instead of exiting
+func resolveHostWithWarning(name string) (remoteHost, bool, string, error) {
+	host, ok, err := findHost(name)
+	if err != nil {
+		return remoteHost{}, false, "", err
+	}
+
+	host, ok, warning, err := applyRepoConfig(name, host, ok)
+	if err != nil {
+		return remoteHost{}, false, "", err
+	}
+
+	if ok {
+		debug.Printf("Resolved host %s: type %s, API %s, token set: %t", name, host.Type, host.API, host.Token != "")
+	} else {
+		debug.Printf("Host %s is not known and not configured", name)
+	}
+
+	return host, ok, warning, nil
+}
+
+// Same as resolveHost, without .pro.yml overrides
+func lookupHost(name string) (remoteHost, bool) {
+	host, ok, err := findHost(name)
+	handleAppError(err)
+
+	return host, ok
+}
+
+// Same as lookupHost, returning GitHub App errors instead of exiting
+func findHost(name string) (remoteHost, bool, error) {
+	host, ok := configuredHost(name)
+	host, err := withTokens(host)
+
+	return host, ok, err
+}
+
+// Use GitHub App or CLI token when the host has one
+func withTokens(host remoteHost) (remoteHost, error) {
+	host, err := withAppToken(host)
+	if err != nil {
+		return host, err
+	}
+
+	return withCLIToken(host), nil
+}
+
+func configuredHost(name string) (remoteHost, bool) {
+	conf := config.Get()
+
+	switch name {
+	case "gitlab.com":
+		return remoteHost{name, "gitlab", gitlab.DefaultBaseURL, conf.GitLabToken}, true
+	case "github.com":
+		return remoteHost{name, "github", github.DefaultBaseURL, conf.GitHubToken}, true
+	case "bitbucket.org":
+		return remoteHost{name, "bitbucket", bitbucket.DefaultBaseURL, conf.BitbucketToken}, true
+	case "codeberg.org":
+		// Token is stored like for self-hosted Gitea, with `pro auth gitea --host codeberg.org`
+		hostConfig, _ := conf.FindHost(name)
+		return remoteHost{name, "gitea", gitea.CodebergBaseURL, hostConfig.Token}, true
+	case "git.sr.ht":
+		// SourceHut has no pull requests, so there is no API to query
+		return remoteHost{name, "sourcehut", "", ""}, true
+	}
+
+	if azure.IsHost(name) {
+		return remoteHost{name, "azure", azure.DefaultBaseURL, conf.AzureToken}, true
+	}
+
+	hostConfig, ok := conf.FindHost(name)
+	if !ok {
+		return remoteHost{}, false
+	}
+
+	return remoteHost{name, hostConfig.Type, hostAPI(hostConfig), hostConfig.Token}, true
+}
+
+// Use token of the official GitHub or GitLab CLI when pro has none, so users
+// logged in with gh or glab don't have to log in again
+func withCLIToken(host remoteHost) remoteHost {
+	if host.Token != "" {
+		return host
+	}
+
+	var cli string
+	switch host.Type {
+	case "github":
+		cli, host.Token = "gh", config.GitHubCLIToken(host.Name)
+	case "gitlab":
+		cli, host.Token = "glab", config.GitLabCLIToken(host.Name)
+	}
+
+	if host.Token != "" {
+		debug.Printf("Using %s token of %s", host.Name, cli)
+	}
+
+	return host
+}
+
+// Override provider type and API base URL with .pro.yml settings. The token is
+// only sent to an API on the remote host or its subdomain, so a cloned
+// repository can't redirect it elsewhere. Returns a warning if the token is
+// dropped.
+func applyRepoConfig(name string, host remoteHost, ok bool) (remoteHost, bool, string, error) {
+	repo := config.Repo()
+	var warning string
+
+	if repo.Provider != "" && repo.Provider != host.Type {
+		hostConfig, _ := config.Get().FindHost(name)
+
+		var err error
+		host, err = withTokens(remoteHost{name, repo.Provider, hostAPI(config.Host{Host: name, Type: repo.Provider}), hostConfig.Token})
+		if err != nil {
+			return remoteHost{}, false, "", err
+		}
+		ok = true
+	}
+
+	if repo.API != "" && ok {
+		host.API = strings.TrimSuffix(repo.API, "/")
+
+		if !isSameSite(host.API, name) && host.Token != "" {
+			warning = fmt.Sprintf("API %s from %s is not on %s, the token won't be sent to it.", host.API, config.RepoConfigFile, name)
+			host.Token = ""
+		}
+	}
+
+	return host, ok, warning, nil
+}
+
+// Check if URL points to given host or its subdomain, e.g. api.github.com for github.com
+func isSameSite(rawURL string, hostName string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	hostName = strings.Split(hostName, ":")[0]
+	urlHost := strings.ToLower(parsed.Hostname())
+
+	return urlHost == hostName || strings.HasSuffix(urlHost, "."+hostName)
+}
+
+// API base URL of a self-hosted instance, defaulting to the standard API path
+func hostAPI(hostConfig config.Host) string {
+	if hostConfig.API != "" {
+		return strings.TrimSuffix(hostConfig.API, "/")
+	}
+
+	switch hostConfig.Type {
+	case "gitlab":
+		return "https://" + hostConfig.Host + "/api/v4"
+	case "github":
+		return "https://" + hostConfig.Host + "/api/v3"
+	case "gitea":
+		return "https://" + hostConfig.Host + "/api/v1"
+	case "gerrit":
+		// REST API is served from the web root
+		return "https://" + hostConfig.Host
+	default:
+		return ""
+	}
+}
+
+// Web URL of the repository home page
+func homeURL(host string, projectPath string) string {
+	if hostConfig, ok := config.Get().FindHost(host); ok && hostConfig.HomeURL != "" {
+		return renderURL(hostConfig.HomeURL, host, projectPath, "", 0)
+	}
+
+	if azure.IsHost(host) {
+		repository, err := azure.ParseRepository(host, projectPath)
+		if err == nil {
+			return repository.WebURL()
+		}
+	}
+
+	return fmt.Sprintf("https://%s/%s", host, projectPath)
+}
+
+// Desktop app URL opening the repository at given branch. Only GitHub Desktop
+// has a URL scheme, false is returned for other providers.
+func appURL(host remoteHost, projectPath string, branch string) (string, bool) {
+	if host.Type != "github" {
+		return "", false
+	}
+
+	return fmt.Sprintf("x-github-client://openRepo/%s?branch=%s", homeURL(host.Name, projectPath), url.QueryEscape(branch)), true
+}
+
+// URL of the pull request diff tab
+func filesURL(hostType string, pullRequestURL string) string {
+	switch hostType {
+	case "github", "gitea":
+		return pullRequestURL + "/files"
+	case "gitlab":
+		return pullRequestURL + "/diffs"
+	case "bitbucket":
+		return pullRequestURL + "/diff"
+	case "azure":
+		return pullRequestURL + "?_a=files"
+	default:
+		return pullRequestURL
+	}
+}
+
+// URL of the page for creating a new pull request from given branch
+func newPullRequestURL(host remoteHost, projectPath string, branch string) string {
+	if hostConfig, ok := config.Get().FindHost(host.Name); ok && hostConfig.CompareURL != "" {
+		return renderURL(hostConfig.CompareURL, host.Name, projectPath, branch, 0)
+	}
+
+	switch host.Type {
+	case "gitlab":
+		return fmt.Sprintf("https://%s/%s/merge_requests/new?merge_request%%5Bsource_branch%%5D=%s", host.Name, projectPath, url.QueryEscape(branch))
+	case "bitbucket":
+		return fmt.Sprintf("https://%s/%s/pull-requests/new?source=%s", host.Name, projectPath, url.QueryEscape(branch))
+	case "gitea":
+		return fmt.Sprintf("https://%s/%s/compare/%s", host.Name, projectPath, providers.EscapePath(branch))
+	case "azure":
+		return fmt.Sprintf("%s/pullrequestcreate?sourceRef=%s", homeURL(host.Name, projectPath), url.QueryEscape(branch))
+	default:
+		return fmt.Sprintf("https://%s/%s/pull/new/%s", host.Name, projectPath, providers.EscapePath(branch))
+	}
+}
+
+// Add query parameter pre-filling the description on the new PR page. Only
+// GitHub and GitLab support it, other URLs are returned unchanged.
+func withDescription(host remoteHost, createURL string, description string) string {
+	var param string
+
+	switch host.Type {
+	case "github":
+		param = "body"
+	case "gitlab":
+		param = "merge_request[description]"
+	default:
+		color.Yellow("%s doesn't support pre-filling the description, --body is ignored.", host.Name)
+		return createURL
+	}
+
+	separator := "?"
+	if strings.Contains(createURL, "?") {
+		separator = "&"
+	}
+
+	return createURL + separator + url.QueryEscape(param) + "=" + url.QueryEscape(description)
+}
+
+// Web page listing pull requests of the branch, opened without an API lookup
+// when the token is not set. Providers without a branch filter list all open
+// pull requests.
+func branchPullRequestsURL(host remoteHost, projectPath string, branch string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		return home + "/-/merge_requests?source_branch=" + url.QueryEscape(branch)
+	case "bitbucket":
+		return home + "/pull-requests?state=OPEN"
+	case "gitea":
+		return home + "/pulls?state=open"
+	case "azure":
+		return home + "/pullrequests?_a=active"
+	default:
+		return home + "/pulls?q=" + url.QueryEscape("is:pr is:open head:"+branch)
+	}
+}
+
+// Fill URL template from the config. Branch is escaped like a path, keeping slashes.
+func renderURL(template string, host string, projectPath string, branch string, number int) string {
+	return strings.NewReplacer(
+		"{host}", host,
+		"{path}", projectPath,
+		"{branch}", providers.EscapePath(branch),
+		"{number}", fmt.Sprint(number),
+	).Replace(template)
+}
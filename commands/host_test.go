@@ -0,0 +1,30 @@
+package commands
+
+import "testing"
+
+func TestNewPullRequestURLEscapesBranch(t *testing.T) {
+	tests := []struct {
+		hostType string
+		hostName string
+		branch   string
+		want     string
+	}{
+		{"github", "github.com", "feature/JIRA-123_fix", "https://github.com/owner/repo/pull/new/feature/JIRA-123_fix"},
+		{"github", "github.com", "fix/über-bug", "https://github.com/owner/repo/pull/new/fix/%C3%BCber-bug"},
+		{"gitea", "gitea.example.com", "feature/JIRA-123_fix", "https://gitea.example.com/owner/repo/compare/feature/JIRA-123_fix"},
+		{"gitea", "gitea.example.com", "fix/über-bug", "https://gitea.example.com/owner/repo/compare/fix/%C3%BCber-bug"},
+		// Branch is a query parameter on GitLab, so slashes are escaped as well
+		{"gitlab", "gitlab.com", "feature/JIRA-123_fix", "https://gitlab.com/owner/repo/merge_requests/new?merge_request%5Bsource_branch%5D=feature%2FJIRA-123_fix"},
+		{"gitlab", "gitlab.com", "fix/über-bug", "https://gitlab.com/owner/repo/merge_requests/new?merge_request%5Bsource_branch%5D=fix%2F%C3%BCber-bug"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hostType+" "+test.branch, func(t *testing.T) {
+			host := remoteHost{Name: test.hostName, Type: test.hostType}
+
+			if url := newPullRequestURL(host, "owner/repo", test.branch); url != test.want {
+				t.Errorf("got %q, want %q", url, test.want)
+			}
+		})
+	}
+}
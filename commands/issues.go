@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type IssuesOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Issue to open, issue list when 0
+	Number int
+	OutputOptions
+	// Print URL as JSON, status messages go to stderr
+	JSON bool
+	// Open issues the PR of the current branch closes instead
+	Linked bool
+}
+
+// Result of the issues command in JSON mode
+type issuesResult struct {
+	URL      string `json:"url"`
+	Provider string `json:"provider"`
+	Number   int    `json:"number,omitempty"`
+}
+
+// Open issue list or a single issue of the repository
+func Issues(repoPath string, options IssuesOptions) {
+	var stdout io.Writer = os.Stdout
+	if options.JSON {
+		stdout = redirectMessagesToStderr()
+	}
+
+	if options.Linked {
+		openLinkedIssues(stdout, repoPath, options)
+		return
+	}
+
+	repository := openRepo(repoPath)
+
+	host, projectPath := resolveRemoteHost(repository, options.Remote)
+
+	url := issuesURL(host, projectPath, options.Number)
+
+	if !options.JSON {
+		showURL(url, options.OutputOptions)
+		return
+	}
+
+	printJSON(stdout, issuesResult{URL: url, Provider: host.Type, Number: options.Number})
+
+	if options.Copy {
+		err := copyToClipboard(url)
+		handleError(err, "Unable to copy to clipboard")
+	}
+}
+
+// URL of the issue list, or of a single issue if number is not 0
+func issuesURL(host remoteHost, projectPath string, number int) string {
+	var url string
+
+	switch host.Type {
+	case "gitlab":
+		url = homeURL(host.Name, projectPath) + "/-/issues"
+	case "sourcehut":
+		// Trackers live on a separate host, usually under the same name as the repository
+		url = "https://todo.sr.ht/" + projectPath
+	case "azure":
+		// Azure DevOps tracks issues as work items of the project
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		if number != 0 {
+			return fmt.Sprintf("%s/_workitems/edit/%d", repository.ProjectURL(), number)
+		}
+
+		return repository.ProjectURL() + "/_workitems"
+	default:
+		url = homeURL(host.Name, projectPath) + "/issues"
+	}
+
+	if number != 0 {
+		url += fmt.Sprintf("/%d", number)
+	}
+
+	return url
+}
+
+// Number of the issue referenced in branch name, matched with issue_patterns
+// from the config or the default ones. Returns false if none matches.
+func branchIssue(branch string) (int, bool) {
+	patterns := config.Current().IssuePatterns
+	if len(patterns) == 0 {
+		patterns = config.DefaultIssuePatterns
+	}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			color.Red("Invalid issue pattern \"%s\" in config: %s", pattern, err)
+			os.Exit(1)
+		}
+
+		match := re.FindStringSubmatch(branch)
+		if len(match) < 2 {
+			continue
+		}
+
+		number, err := strconv.Atoi(match[1])
+		if err == nil && number > 0 {
+			return number, true
+		}
+	}
+
+	return 0, false
+}
+
+// Closing keywords of GitHub, GitLab and Gitea followed by an issue reference,
+// e.g. "Fixes #12" or "closes: #34"
+var closingReference = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?|implement(?:s|ed)?):?\s+#(\d+)\b`)
+
+// Print issues the PR of the current branch closes and open the first one
+func openLinkedIssues(stdout io.Writer, repoPath string, options IssuesOptions) {
+	project := resolveProject(repoPath, options.Remote)
+	host := project.host
+
+	if host.Type != "github" && host.Type != "gitlab" && host.Type != "gitea" {
+		color.Red("Finding linked issues is not supported for %s yet.", host.Name)
+		os.Exit(1)
+	}
+
+	pullRequest, ok := findPullRequest(host, project.branch, project.path)
+	if !ok {
+		fmt.Println("No open pull request found for current branch")
+		os.Exit(exitNoPullRequest)
+	}
+
+	body, err := pullRequestBody(host, project.path, pullRequest.Number)
+	handleProviderError(host, err, "Unable to get pull request")
+
+	numbers := linkedIssues(body)
+	if len(numbers) == 0 {
+		fmt.Printf("Pull request #%d doesn't reference issues it closes, e.g. with \"Fixes #12\"\n", pullRequest.Number)
+		os.Exit(1)
+	}
+
+	var results []issuesResult
+	for _, number := range numbers {
+		results = append(results, issuesResult{URL: issuesURL(host, project.path, number), Provider: host.Type, Number: number})
+	}
+
+	if options.JSON {
+		printJSON(stdout, results)
+		return
+	}
+
+	if len(results) > 1 {
+		printInfo("Pull request #%d closes %d issues:\n", pullRequest.Number, len(results))
+		for _, result := range results {
+			printInfo("  #%d %s\n", result.Number, color.BlueString(result.URL))
+		}
+		printInfo("Opening the first one, use `pro issues <number>` to open another one.\n")
+	}
+
+	showURL(results[0].URL, options.OutputOptions)
+}
+
+// Description of a pull request
+func pullRequestBody(host remoteHost, projectPath string, number int) (string, error) {
+	switch host.Type {
+	case "github":
+		pullRequest, err := github.PullRequest(host.API, projectPath, host.Token, number)
+		return pullRequest.Body, err
+	case "gitlab":
+		mergeRequest, err := gitlab.MergeRequest(host.API, projectPath, host.Token, number)
+		return mergeRequest.Description, err
+	case "gitea":
+		pullRequest, err := gitea.PullRequest(host.API, projectPath, host.Token, number)
+		return pullRequest.Body, err
+	default:
+		return "", errors.New("unknown remote type")
+	}
+}
+
+// Numbers of issues referenced with closing keywords, in order of appearance
+func linkedIssues(body string) []int {
+	var numbers []int
+	seen := map[int]bool{}
+
+	for _, match := range closingReference.FindAllStringSubmatch(body, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || seen[number] {
+			continue
+		}
+
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type LinkOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	OutputOptions
+}
+
+// Lines of a file to link to, zero values mean the whole file
+type lineRange struct {
+	start int
+	end   int
+}
+
+// Open permalink to a file or lines of it at the HEAD commit.
+// Target is a path relative to the current directory, optionally followed
+// by lines, e.g. "main.go", "main.go:10" or "main.go:10-20".
+func Link(repoPath string, target string, options LinkOptions) {
+	file, lines, err := parseLinkTarget(target)
+	handleError(err, "Invalid file")
+
+	repository := openRepo(repoPath)
+
+	remoteName := options.Remote
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	host, projectPath := resolveRemoteHost(repository, remoteName)
+
+	worktree, err := repository.Worktree()
+	handleError(err, "Unable to find repository root")
+
+	filePath, err := repositoryRelativePath(worktree.Filesystem.Root(), file)
+	handleError(err, "Invalid file")
+
+	head := repositoryHead(repository).Hash()
+	if !isPushed(repository, remoteName, head) {
+		color.Yellow("HEAD commit is not pushed to %s yet, the link won't work until it is.", remoteName)
+	}
+
+	showURL(permalinkURL(host, projectPath, head.String(), filePath, lines), options.OutputOptions)
+}
+
+func parseLinkTarget(target string) (string, lineRange, error) {
+	file, linesPart, hasLines := strings.Cut(target, ":")
+	if !hasLines {
+		return file, lineRange{}, nil
+	}
+
+	startPart, endPart, hasEnd := strings.Cut(linesPart, "-")
+
+	start, err := strconv.Atoi(startPart)
+	if err != nil || start <= 0 {
+		return "", lineRange{}, fmt.Errorf("invalid line \"%s\", use file:10 or file:10-20", startPart)
+	}
+
+	end := start
+	if hasEnd {
+		end, err = strconv.Atoi(endPart)
+		if err != nil || end < start {
+			return "", lineRange{}, fmt.Errorf("invalid line range \"%s\", use file:10-20", linesPart)
+		}
+	}
+
+	return file, lineRange{start, end}, nil
+}
+
+// Path of the file relative to the repository root, with forward slashes
+func repositoryRelativePath(root string, file string) (string, error) {
+	absolutePath, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absolutePath)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", file)
+	}
+
+	relativePath, err := filepath.Rel(root, absolutePath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return "", fmt.Errorf("%s is outside of the repository", file)
+	}
+
+	return filepath.ToSlash(relativePath), nil
+}
+
+// Web URL of the file at given commit, with lines highlighted
+func permalinkURL(host remoteHost, projectPath string, sha string, filePath string, lines lineRange) string {
+	escapedPath := providers.EscapePath(filePath)
+
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		return fmt.Sprintf("%s/-/blob/%s/%s", home, sha, escapedPath) + lines.anchor("#L%d", "#L%d-%d")
+	case "bitbucket":
+		return fmt.Sprintf("%s/src/%s/%s", home, sha, escapedPath) + lines.anchor("#lines-%d", "#lines-%d:%d")
+	case "gitea":
+		return fmt.Sprintf("%s/src/commit/%s/%s", home, sha, escapedPath) + lines.anchor("#L%d", "#L%d-L%d")
+	case "sourcehut":
+		return fmt.Sprintf("%s/tree/%s/item/%s", home, sha, escapedPath) + lines.anchor("#L%d", "#L%d-%d")
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		query := url.Values{}
+		query.Set("path", "/"+filePath)
+		query.Set("version", "GC"+sha)
+		if lines.start != 0 {
+			// Selection ends at the start of the line after the range
+			query.Set("line", fmt.Sprint(lines.start))
+			query.Set("lineEnd", fmt.Sprint(lines.end+1))
+			query.Set("lineStartColumn", "1")
+			query.Set("lineEndColumn", "1")
+		}
+
+		return repository.WebURL() + "?" + query.Encode()
+	default:
+		return fmt.Sprintf("%s/blob/%s/%s", home, sha, escapedPath) + lines.anchor("#L%d", "#L%d-L%d")
+	}
+}
+
+// Format lines with single line or range format, empty for the whole file
+func (l lineRange) anchor(singleFormat string, rangeFormat string) string {
+	switch {
+	case l.start == 0:
+		return ""
+	case l.start == l.end:
+		return fmt.Sprintf(singleFormat, l.start)
+	default:
+		return fmt.Sprintf(rangeFormat, l.start, l.end)
+	}
+}
+
+// Check if commit is reachable from any branch of the remote. Links to
+// commits which are not pushed lead to a 404 page.
+func isPushed(repository *git.Repository, remoteName string, hash plumbing.Hash) bool {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return false
+	}
+
+	references, err := repository.References()
+	if err != nil {
+		return false
+	}
+
+	pushed := false
+
+	_ = references.ForEach(func(reference *plumbing.Reference) error {
+		if pushed || !reference.Name().IsRemote() || !strings.HasPrefix(reference.Name().Short(), remoteName+"/") {
+			return nil
+		}
+
+		if reference.Hash() == hash {
+			pushed = true
+			return nil
+		}
+
+		remoteCommit, err := repository.CommitObject(reference.Hash())
+		if err == nil {
+			pushed, _ = commit.IsAncestor(remoteCommit)
+		}
+
+		return nil
+	})
+
+	return pushed
+}
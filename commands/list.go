@@ -0,0 +1,289 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type ListOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Only show pull requests authored by the authenticated user
+	Mine bool
+	// Only show pull requests waiting for review of the authenticated user
+	ReviewRequested bool
+	// Print pull requests as JSON, status messages go to stderr
+	JSON bool
+	// Maximum number of pull requests to show, 0 means all
+	Limit int
+}
+
+// Open pull request shown by the list command
+type pullRequestListItem struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Branch string `json:"branch"`
+	URL    string `json:"url"`
+	Draft  bool   `json:"draft"`
+	// Provider-specific user identifiers used to filter own pull requests and
+	// those waiting for review
+	authorID    string
+	reviewerIDs []string
+}
+
+// Print all open pull requests of the repository
+func List(repoPath string, options ListOptions) {
+	var stdout io.Writer = os.Stdout
+	if options.JSON {
+		stdout = redirectMessagesToStderr()
+	}
+
+	repository := openRepo(repoPath)
+
+	host, projectPath := resolveRemoteHost(repository, options.Remote)
+
+	if host.Type == "sourcehut" {
+		color.Red("SourceHut doesn't have pull requests, patches are sent to mailing lists.")
+		os.Exit(1)
+	}
+
+	if host.Token == "" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	// Own pull requests are filtered after fetching, so all of them are needed
+	limit := options.Limit
+	if options.Mine || options.ReviewRequested {
+		limit = 0
+	}
+
+	pullRequests, err := listPullRequests(host, projectPath, limit)
+	handleProviderError(host, err, "Unable to get pull requests")
+
+	if options.Mine || options.ReviewRequested {
+		userID, err := currentUserID(host)
+		handleProviderError(host, err, "Unable to get current user")
+
+		pullRequests = filterPullRequests(pullRequests, func(pullRequest pullRequestListItem) bool {
+			return (!options.Mine || pullRequest.authorID == userID) &&
+				(!options.ReviewRequested || pullRequest.isReviewRequested(userID))
+		})
+	}
+
+	if options.Limit > 0 && len(pullRequests) > options.Limit {
+		pullRequests = pullRequests[:options.Limit]
+	}
+
+	if options.JSON {
+		// Print empty list instead of null
+		if pullRequests == nil {
+			pullRequests = []pullRequestListItem{}
+		}
+
+		printJSON(stdout, pullRequests)
+		return
+	}
+
+	if len(pullRequests) == 0 {
+		fmt.Println("No open pull requests found")
+		return
+	}
+
+	writer := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, pullRequest := range pullRequests {
+		title := pullRequest.Title
+		if pullRequest.Draft {
+			title = color.YellowString("[draft] ") + title
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+			color.GreenString("#%d", pullRequest.Number),
+			title,
+			pullRequest.Author,
+			color.BlueString(pullRequest.Branch),
+		)
+	}
+	writer.Flush()
+}
+
+// Pull requests for which keep returns true
+func filterPullRequests(pullRequests []pullRequestListItem, keep func(pullRequestListItem) bool) []pullRequestListItem {
+	var filtered []pullRequestListItem
+	for _, pullRequest := range pullRequests {
+		if keep(pullRequest) {
+			filtered = append(filtered, pullRequest)
+		}
+	}
+
+	return filtered
+}
+
+// Whether the user is requested to review the pull request
+func (p pullRequestListItem) isReviewRequested(userID string) bool {
+	for _, reviewerID := range p.reviewerIDs {
+		if reviewerID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Exit with a message if provider API call failed, suggesting to log in
+// again if the token was rejected
+func handleProviderError(host remoteHost, err error, reason string) {
+	if err == nil {
+		return
+	}
+
+	exitIfRateLimited(err)
+
+	color.Red("%s: %s", reason, err.Error())
+
+	if errors.Is(err, gitlab.ErrInsufficientScope) {
+		fmt.Printf("GitLab token needs 'read_api' scope. Create a new token and run `%s` to set it.\n", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	if host.Type == "gerrit" && errors.Is(err, providers.ErrUnauthorized) {
+		fmt.Printf("Set token of %s in the config file as \"username:http_password\".\n", host.Name)
+		os.Exit(exitAuthError)
+	}
+
+	if isUnauthorizedError(err) {
+		fmt.Println("Token may be expired or revoked. " + host.reauthHint())
+		os.Exit(exitAuthError)
+	}
+
+	os.Exit(1)
+}
+
+// Whether err means the token was rejected by any provider
+func isUnauthorizedError(err error) bool {
+	return errors.Is(err, providers.ErrUnauthorized) || errors.Is(err, gitlab.ErrTokenExpired)
+}
+
+// Open pull requests of the repository, limit caps their number, 0 means all
+func listPullRequests(host remoteHost, projectPath string, limit int) ([]pullRequestListItem, error) {
+	var items []pullRequestListItem
+
+	switch host.Type {
+	case "github":
+		pullRequests, err := github.ListPullRequests(host.API, projectPath, host.Token, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pullRequests {
+			var reviewers []string
+			for _, reviewer := range p.RequestedReviewers {
+				reviewers = append(reviewers, reviewer.Login)
+			}
+
+			items = append(items, pullRequestListItem{p.Number, p.Title, p.User.Login, p.Head.Ref, p.HtmlURL, p.Draft, p.User.Login, reviewers})
+		}
+	case "gitlab":
+		mergeRequests, err := gitlab.ListMergeRequests(host.API, projectPath, host.Token, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range mergeRequests {
+			var reviewers []string
+			for _, reviewer := range m.Reviewers {
+				reviewers = append(reviewers, reviewer.Username)
+			}
+
+			items = append(items, pullRequestListItem{m.IID, m.Title, m.Author.Username, m.SourceBranch, m.WebUrl, m.IsDraft(), m.Author.Username, reviewers})
+		}
+	case "bitbucket":
+		pullRequests, err := bitbucket.ListPullRequests(projectPath, host.Token, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pullRequests {
+			var reviewers []string
+			for _, reviewer := range p.Reviewers {
+				reviewers = append(reviewers, reviewer.UUID)
+			}
+
+			items = append(items, pullRequestListItem{p.ID, p.Title, p.Author.DisplayName, p.Source.Branch.Name, p.Links.Html.Href, p.Draft, p.Author.UUID, reviewers})
+		}
+	case "gitea":
+		pullRequests, err := gitea.ListPullRequests(host.API, projectPath, host.Token, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pullRequests {
+			var reviewers []string
+			for _, reviewer := range p.RequestedReviewers {
+				reviewers = append(reviewers, reviewer.Login)
+			}
+
+			items = append(items, pullRequestListItem{p.Number, p.Title, p.User.Login, p.Head.Ref, p.HtmlURL, p.IsDraft(), p.User.Login, reviewers})
+		}
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		pullRequests, err := azure.ListPullRequests(repository, host.Token, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pullRequests {
+			// Reviewers who already voted are not waiting to review
+			var reviewers []string
+			for _, reviewer := range p.Reviewers {
+				if reviewer.Vote == 0 {
+					reviewers = append(reviewers, reviewer.ID)
+				}
+			}
+
+			branch := strings.TrimPrefix(p.SourceRefName, "refs/heads/")
+			items = append(items, pullRequestListItem{p.ID, p.Title, p.CreatedBy.DisplayName, branch, p.WebURL, p.IsDraft, p.CreatedBy.ID, reviewers})
+		}
+	}
+
+	return items, nil
+}
+
+// Identifier of the authenticated user matching pullRequestListItem.authorID
+func currentUserID(host remoteHost) (string, error) {
+	switch host.Type {
+	case "github":
+		user, err := github.User(host.API, host.Token)
+		return user.Login, err
+	case "gitlab":
+		user, err := gitlab.User(host.API, host.Token)
+		return user.Username, err
+	case "bitbucket":
+		user, err := bitbucket.User(host.Token)
+		return user.UUID, err
+	case "gitea":
+		user, err := gitea.User(host.API, host.Token)
+		return user.Login, err
+	case "azure":
+		profile, err := azure.User(host.Token)
+		return profile.ID, err
+	default:
+		return "", errors.New("unknown remote type")
+	}
+}
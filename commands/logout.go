@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/wowu/pro/config"
+
+	"github.com/fatih/color"
+)
+
+// Remove stored tokens. Empty provider removes tokens of all providers and hosts.
+func Logout(provider string, host string) {
+	conf := config.Get()
+
+	var removed []string
+
+	clear := func(name string, token *string) {
+		if *token != "" {
+			*token = ""
+			removed = append(removed, name)
+		}
+	}
+
+	if host != "" {
+		for i := range conf.Hosts {
+			if conf.Hosts[i].Host == host && (provider == "" || conf.Hosts[i].Type == provider) {
+				clear(host, &conf.Hosts[i].Token)
+			}
+		}
+	} else {
+		if provider == "" || provider == "github" {
+			clear("GitHub", &conf.GitHubToken)
+		}
+		if provider == "" || provider == "gitlab" {
+			clear("GitLab", &conf.GitLabToken)
+		}
+		if provider == "" || provider == "bitbucket" {
+			clear("Bitbucket", &conf.BitbucketToken)
+		}
+		if provider == "" || provider == "azure" {
+			clear("Azure DevOps", &conf.AzureToken)
+		}
+		if provider == "" {
+			for i := range conf.Hosts {
+				clear(conf.Hosts[i].Host, &conf.Hosts[i].Token)
+			}
+		}
+	}
+
+	if len(removed) == 0 {
+		fmt.Println("No token is set, nothing to remove.")
+		return
+	}
+
+	saveConfig(conf)
+
+	for _, name := range removed {
+		color.Green("Removed %s token.", name)
+	}
+}
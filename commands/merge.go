@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+type MergeOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// "merge", "squash" or "rebase", a merge commit is created when empty
+	Method string
+	// Delete the branch on the remote after merging
+	DeleteBranch bool
+	// Merge without asking for confirmation
+	Yes bool
+}
+
+// Merge the current branch's pull request
+func Merge(repoPath string, options MergeOptions) {
+	project := resolveProject(repoPath, options.Remote)
+	host := project.host
+
+	if host.Type != "github" && host.Type != "gitlab" {
+		color.Red("Merging is not supported for %s yet.", host.Name)
+		os.Exit(1)
+	}
+
+	// GitLab uses the merge method from project settings, it can only be told to squash
+	if host.Type == "gitlab" && options.Method == "rebase" {
+		color.Red("GitLab merges with the method set in project settings, --rebase is not supported.")
+		os.Exit(1)
+	}
+
+	pullRequest, ok := selectPullRequest(findPullRequests(host, project.branch, project.path, ""), false)
+	if !ok {
+		fmt.Println("No open pull request found for current branch")
+		os.Exit(exitNoPullRequest)
+	}
+
+	if !options.Yes {
+		confirmMerge(pullRequest, options.Method)
+	}
+
+	switch host.Type {
+	case "github":
+		err := github.MergePullRequest(host.API, project.path, host.Token, pullRequest.Number, options.Method)
+		handleMergeError(host, err)
+
+		color.Green("Merged pull request #%d: %s", pullRequest.Number, pullRequest.Title)
+
+		if options.DeleteBranch {
+			err = github.DeleteBranch(host.API, project.path, host.Token, project.branch)
+			switch {
+			case err == nil:
+				color.Green("Deleted branch %s", project.branch)
+			case errors.Is(err, github.ErrNotFound):
+				// Already deleted by the repository's auto-delete setting
+			default:
+				color.Red("Unable to delete branch %s: %s", project.branch, err)
+				os.Exit(1)
+			}
+		}
+	case "gitlab":
+		_, err := gitlab.MergeMergeRequest(host.API, project.path, host.Token, pullRequest.Number, gitlab.AcceptMergeRequest{
+			Squash:                   options.Method == "squash",
+			ShouldRemoveSourceBranch: options.DeleteBranch,
+		})
+		handleMergeError(host, err)
+
+		color.Green("Merged merge request !%d: %s", pullRequest.Number, pullRequest.Title)
+	}
+}
+
+// Ask before merging, exit if the user doesn't agree or can't be asked
+func confirmMerge(pullRequest pullRequestInfo, method string) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		color.Red("Unable to ask for confirmation, use --yes to merge #%d.", pullRequest.Number)
+		os.Exit(1)
+	}
+
+	if method == "" {
+		method = "merge"
+	}
+
+	answer := readLine(fmt.Sprintf("Merge #%d %s with %s? [y/N]: ", pullRequest.Number, color.CyanString(pullRequest.Title), method))
+	if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+		fmt.Println("Not merged")
+		os.Exit(1)
+	}
+}
+
+// Exit with the reason the pull request wasn't merged, e.g. a branch protection rule
+func handleMergeError(host remoteHost, err error) {
+	if errors.Is(err, gitlab.ErrInsufficientScope) {
+		color.Red("Unable to merge: %s", err.Error())
+		fmt.Printf("Merging requires a GitLab token with 'api' scope. Run `%s` to set a new token.\n", host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	handleProviderError(host, err, "Unable to merge")
+}
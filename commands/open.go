@@ -3,196 +3,883 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gerrit"
+	"github.com/wowu/pro/providers/gitea"
 	"github.com/wowu/pro/providers/github"
 	"github.com/wowu/pro/providers/gitlab"
 
 	"github.com/fatih/color"
 	"github.com/go-git/go-git/v5"
-	giturls "github.com/whilp/git-urls"
+	"golang.org/x/term"
 )
 
-func Open(repoPath string, print bool) {
-	repository, err := findRepo(repoPath)
-	if err != nil {
-		color.Red("Unable to find git repository in given directory or any of parent directories.")
-		fmt.Println("Please make sure you are in the project directory.")
-		os.Exit(1)
+type OpenOptions struct {
+	// Name of the remote. When empty, default remote is used and the "upstream"
+	// remote is checked as well, as PRs from forks are usually opened against upstream.
+	Remote string
+	// Branch to look up PR for instead of the current one
+	Branch string
+	OutputOptions
+	// Look up PR even on main branches
+	ForcePR bool
+	// Print result as JSON, status messages go to stderr
+	JSON bool
+	// Always look up PR in the API instead of using cached result
+	NoCache bool
+	// Open PR with given number instead of looking it up by branch
+	Number int
+	// Open new PR page when no PR is found instead of printing its URL
+	CreateIfMissing bool
+	// Open the diff instead of the conversation tab
+	Files bool
+	// Only print what would be looked up and opened, without API requests
+	DryRun bool
+	// Open the branch in the provider's desktop app instead of the browser
+	App bool
+	// Use the first PR when more match the branch instead of asking
+	First bool
+	// Only match PRs targeting this branch
+	Base string
+	// Look up PR in every remote until one is found instead of only the default one
+	AllRemotes bool
+	// Don't warn about commits which are not pushed yet
+	NoPushCheck bool
+	// Open the first PR waiting for review of the authenticated user instead
+	// of the PR of a branch
+	ReviewRequested bool
+	// Open the issue referenced in the branch name instead of the PR
+	Issue bool
+	// Detect provider of an unknown host by requesting its API and save it in the config
+	Probe bool
+	// Print result with a Go template or one of formatPresets instead of the URL
+	Format string
+	// Open every PR of the branch, e.g. in stacked PR workflows, instead of one
+	All bool
+	// Description pre-filled on the new PR page when no PR is found
+	Body string
+	// Open PR list filtered by the branch when the token is not set, instead of failing
+	WebLogin bool
+	// Poll the provider until a PR for the branch is opened, e.g. right after pushing
+	Wait bool
+	// Time between lookups with Wait, defaultWaitInterval when 0
+	WaitInterval time.Duration
+	// Maximum time to wait with Wait, defaultWaitTimeout when 0
+	WaitTimeout time.Duration
+}
+
+// Number of browser tabs --all opens without asking
+const maxTabs = 5
+
+// Defaults of --wait-interval and --wait-timeout
+const (
+	defaultWaitInterval = 5 * time.Second
+	defaultWaitTimeout  = 5 * time.Minute
+)
+
+// Named templates accepted by --format
+var formatPresets = map[string]string{
+	"url":      "{{.URL}}",
+	"markdown": "[#{{.Number}}]({{.URL}})",
+}
+
+// Open pull request for the current branch
+func Open(repoPath string, options OpenOptions) {
+	remoteName := options.Remote
+
+	var stdout io.Writer = os.Stdout
+	if options.JSON {
+		stdout = redirectMessagesToStderr()
 	}
 
-	// check if there is a remote named origin
-	origin, err := repository.Remote("origin")
-	if err != nil {
-		color.Red("No remote named \"origin\" found.")
-		fmt.Println("Please make sure you have a remote named \"origin\".")
-		os.Exit(1)
+	repository := openRepo(repoPath)
+
+	checkUpstream := remoteName == ""
+	if checkUpstream {
+		remoteName = defaultRemote()
+	}
+
+	if options.DryRun {
+		dryRunOpen(repository, remoteName, checkUpstream, options)
+		return
+	}
+
+	if options.AllRemotes {
+		openAllRemotes(stdout, repository, remoteBranch(repository, lookupBranch(repository, options.Branch)), options)
+		return
+	}
+
+	remote := getRemote(repository, remoteName)
+
+	if options.ReviewRequested {
+		openReviewRequested(stdout, preferredURL(repository, remote), options)
+		return
+	}
+
+	if options.Number != 0 {
+		openNumber(stdout, preferredURL(repository, remote), options)
+		return
 	}
 
-	// get current head
-	head, err := repository.Head()
-	handleError(err, "Unable to get repository head")
+	if options.Branch == "" {
+		if tag, ok := checkedOutTag(repository); ok {
+			openTag(stdout, preferredURL(repository, remote), tag, options)
+			return
+		}
+	}
 
-	if !head.Name().IsBranch() {
-		color.Red("No active branch found.")
-		fmt.Println("Switch to a branch and try again.")
+	resolveOptions := ResolveOptions{
+		Remote:  options.Remote,
+		Branch:  options.Branch,
+		ForcePR: options.ForcePR,
+		Base:    options.Base,
+		// Cache holds a single PR, all of them are looked up with --all
+		Cache:       !options.NoCache && !options.All,
+		NoPushCheck: options.NoPushCheck,
+	}
+
+	target := openTarget(repository, resolveOptions)
+	host := target.host
+
+	if options.Issue {
+		if !target.known {
+			exitUnknownRemote()
+		}
+
+		openBranchIssue(stdout, host, target.projectPath, target.localBranch, options)
+		return
+	}
+
+	if options.App && !options.JSON {
+		if url, ok := appURL(host, target.projectPath, target.branch); ok {
+			showURL(url, options.OutputOptions)
+			return
+		}
+
+		color.Yellow("%s can't be opened in a desktop app, opening in browser instead.", target.hostName)
+	}
+
+	// Changes are looked up by commit, so Gerrit users can stay on the main branch
+	if target.known && host.Type == "gerrit" {
+		openGerritChange(stdout, repository, host, target.branch, target.projectPath, options)
+		return
+	}
+
+	if !target.known && options.Probe {
+		target.host, target.known = probeHost(target.hostName)
+		host = target.host
+	}
+
+	result, err := target.resolve(resolveOptions)
+	switch {
+	case errors.Is(err, ErrUnknownHost):
+		exitUnknownRemote()
+	case errors.Is(err, ErrNoToken) && options.WebLogin:
+		printInfo("Token for %s is not set, opening pull requests of the branch.\n", host.Name)
+		showResult(stdout, Result{Branch: target.branch, URL: branchPullRequestsURL(host, target.projectPath, target.branch), Provider: host.Type}, options)
+		return
+	}
+	handleLookupError(host, target.projectPath, err)
+
+	if result.Home {
+		printInfo("Looks like you are on the main branch. Opening home page.\n")
+		showResult(stdout, result, options)
 		os.Exit(0)
 	}
 
-	// get current branch name
-	branch := head.Name().Short()
-	fmt.Printf("Current branch: %s\n", color.GreenString(branch))
+	switch host.Type {
+	case "sourcehut":
+		// SourceHut uses mailing lists instead of pull requests, so the branch log is opened
+		color.Yellow("SourceHut doesn't have pull requests, opening the branch log instead.")
+		fmt.Println("To send the branch for review, use `git send-email`, see https://git-send-email.io")
+		showResult(stdout, result, options)
+		return
+	case "custom":
+		hostConfig, _ := config.Get().FindHost(host.Name)
+		openCustomURL(stdout, host, "compare_url", hostConfig.CompareURL, target.projectPath, target.branch, 0, options)
+		return
+	}
+
+	pullRequests := result.pullRequests
+	waited := false
+
+	if len(pullRequests) == 0 && options.Wait {
+		pullRequests = waitForPullRequests(func() []pullRequestInfo {
+			pullRequests, err := target.lookup(options.Base)
+			handleLookupError(host, target.projectPath, err)
+			return pullRequests
+		}, options.WaitInterval, options.WaitTimeout)
+		waited = true
+	}
+
+	if options.All && len(pullRequests) > 0 {
+		openAllPullRequests(stdout, host, target.branch, pullRequests, options)
+		return
+	}
+
+	pullRequest, found := selectPullRequest(pullRequests, options.First || options.JSON)
+
+	if cache, useCache := target.pullRequestCache(resolveOptions); found && useCache && (waited || len(pullRequests) > 1) {
+		cache.set(pullRequest)
+	}
+
+	if number, ok := branchIssue(target.localBranch); ok && !options.JSON {
+		printInfo("Branch references issue #%d, open it with `pro --issue`\n", number)
+	}
+
+	if found {
+		showResult(stdout, Result{
+			Branch:   target.branch,
+			URL:      pullRequest.URL,
+			Provider: host.Type,
+			Number:   pullRequest.Number,
+			Title:    pullRequest.Title,
+			Draft:    pullRequest.Draft,
+		}, options)
+		return
+	}
+
+	createURL := result.CreateURL
+	if options.Body != "" {
+		createURL = withDescription(host, createURL, options.Body)
+	}
+
+	fmt.Println("No open pull request found for current branch")
+
+	if options.CreateIfMissing && !options.JSON {
+		showURL(createURL, options.OutputOptions)
+		os.Exit(exitNoPullRequest)
+	}
+
+	fmt.Println("Create pull request at", color.BlueString(createURL))
+
+	if options.JSON {
+		printJSON(stdout, Result{Branch: target.branch, CreateURL: createURL, Provider: host.Type})
+	}
+
+	os.Exit(exitNoPullRequest)
+}
+
+// Find branch and host of the lookup, printing them and warnings as they are
+// found, or exit
+func openTarget(repository *git.Repository, options ResolveOptions) *target {
+	target, err := findTarget(repository, options, reporter{
+		info: printInfo,
+		warn: func(message string) { color.Yellow("%s", message) },
+	})
+	handleBranchError(err)
+	handleAppError(err)
+	handleError(err, "Unable to find pull request")
+
+	if target.detached {
+		printInfo("HEAD is detached, using branch %s pointing at the same commit\n", color.GreenString(target.localBranch))
+	}
+
+	if options.Branch == "" {
+		printInfo("Current branch: %s\n", color.GreenString(target.localBranch))
+	} else {
+		printInfo("Branch: %s\n", color.GreenString(target.localBranch))
+	}
 
-	originURL := origin.Config().URLs[0]
+	if target.branch != target.localBranch {
+		printInfo("Branch is pushed as %s\n", color.GreenString(target.branch))
+	}
 
-	gitURL, err := giturls.Parse(originURL)
-	handleError(err, "Unable to parse origin URL")
+	return target
+}
 
-	if branch == "master" || branch == "main" || branch == "trunk" || branch == "develop" {
-		fmt.Println("Looks like you are on the main branch. Opening home page.")
+// Repeat lookup until it finds pull requests or timeout passes, returning
+// nothing on timeout
+func waitForPullRequests(lookup func() []pullRequestInfo, interval time.Duration, timeout time.Duration) []pullRequestInfo {
+	if interval == 0 {
+		interval = defaultWaitInterval
+	}
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
 
-		homeUrl := fmt.Sprintf("https://%s/%s", gitURL.Host, strings.TrimPrefix(gitURL.Path, "/"))
-		homeUrl = strings.TrimSuffix(homeUrl, ".git")
+	printInfo("Waiting up to %s for a pull request to be opened\n", timeout)
 
-		if print {
-			color.Blue(homeUrl)
+	deadline := time.Now().Add(timeout)
+	for remaining := timeout; remaining > 0; remaining = time.Until(deadline) {
+		if remaining < interval {
+			time.Sleep(remaining)
 		} else {
-			color.Blue(homeUrl)
-			openBrowser(homeUrl)
+			time.Sleep(interval)
 		}
+		debug.Printf("Looking up pull requests again")
 
-		os.Exit(0)
+		if pullRequests := lookup(); len(pullRequests) > 0 {
+			return pullRequests
+		}
 	}
 
-	projectPath := strings.TrimPrefix(gitURL.Path, "/")
-	projectPath = strings.TrimSuffix(projectPath, ".git")
+	color.Yellow("No pull request was opened within %s.", timeout)
 
-	switch gitURL.Host {
-	case "gitlab.com":
-		openGitLab(branch, projectPath, print)
-	case "github.com":
-		openGitHub(branch, projectPath, print)
-	default:
-		fmt.Println("Unknown remote type")
+	return nil
+}
+
+// Branch to look up PR for, the current one if branch is empty
+func lookupBranch(repository *git.Repository, branch string) string {
+	if branch == "" {
+		branch = currentBranch(repository)
+		printInfo("Current branch: %s\n", color.GreenString(branch))
+	} else {
+		warnIfNoLocalBranch(repository, branch)
+		printInfo("Branch: %s\n", color.GreenString(branch))
+	}
+
+	return branch
+}
+
+// Name of the branch on the remote, which PRs are opened from
+func remoteBranch(repository *git.Repository, branch string) string {
+	pushed := pushedBranch(repository, branch)
+	if pushed != branch {
+		printInfo("Branch is pushed as %s\n", color.GreenString(pushed))
+	}
+
+	return pushed
+}
+
+// Detect provider of an unknown host and save it in the config, so next time
+// the host is known without probing
+func probeHost(hostName string) (remoteHost, bool) {
+	printInfo("Probing %s to detect its provider\n", hostName)
+
+	hostType, ok := providers.Probe(hostName)
+	if !ok {
+		color.Red("Unable to detect provider of %s.", hostName)
+		fmt.Printf("Add it with `pro auth <provider> --host %s`.\n", hostName)
+		return remoteHost{}, false
+	}
+
+	conf := config.Get()
+	conf.SetHostToken(hostName, hostType, "")
+	saveConfig(conf)
+
+	color.Green("Detected %s at %s, saved it in the config.", hostType, hostName)
+
+	return resolveHost(hostName)
+}
+
+// Open the issue referenced in the branch name, e.g. "fix/GH-456"
+func openBranchIssue(stdout io.Writer, host remoteHost, projectPath string, branch string, options OpenOptions) {
+	number, ok := branchIssue(branch)
+	if !ok {
+		color.Red("No issue number found in branch name %s.", branch)
+		fmt.Println("Set issue_patterns in the config to match your branch naming convention.")
 		os.Exit(1)
 	}
+
+	showResult(stdout, Result{
+		Branch:   branch,
+		URL:      issuesURL(host, projectPath, number),
+		Provider: host.Type,
+		Number:   number,
+	}, options)
 }
 
-// Find git repository in given directory or parent directories
-func findRepo(path string) (*git.Repository, error) {
-	absolutePath, err := filepath.Abs(path)
-	if err != nil {
-		return nil, err
+// Open the first pull request of the repository the authenticated user is
+// requested to review
+func openReviewRequested(stdout io.Writer, remoteURL string, options OpenOptions) {
+	hostName, projectPath, err := parseRemoteURL(remoteURL)
+	handleError(err, "Unable to parse remote URL")
+
+	host, ok := resolveHost(hostName)
+	if _, hasProvider := pullRequestProvider(host); !ok || !hasProvider {
+		color.Red("Finding pull requests to review is not supported for %s.", hostName)
+		os.Exit(1)
+	}
+
+	if host.Token == "" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
 	}
 
-	repository, err := git.PlainOpen(absolutePath)
+	pullRequests, err := listPullRequests(host, projectPath, 0)
+	handleProviderError(host, err, "Unable to get pull requests")
+
+	userID, err := currentUserID(host)
+	handleProviderError(host, err, "Unable to get current user")
 
-	if err == nil {
-		return repository, nil
+	pullRequests = filterPullRequests(pullRequests, func(pullRequest pullRequestListItem) bool {
+		return pullRequest.isReviewRequested(userID)
+	})
+
+	if len(pullRequests) == 0 {
+		fmt.Println("No pull requests waiting for your review")
+		os.Exit(exitNoPullRequest)
 	}
 
-	if errors.Is(err, git.ErrRepositoryNotExists) {
-		// Base case - we've reached the root of the filesystem
-		if absolutePath == "/" {
-			return nil, errors.New("no git repository found")
-		}
+	if len(pullRequests) > 1 {
+		printInfo("%d pull requests are waiting for your review, see them with `pro list --review-requested`\n", len(pullRequests))
+	}
 
-		// Recurse to parent directory
-		return findRepo(filepath.Dir(absolutePath))
+	pullRequest := pullRequests[0]
+	showResult(stdout, Result{
+		Branch:   pullRequest.Branch,
+		URL:      pullRequest.URL,
+		Provider: host.Type,
+		Number:   pullRequest.Number,
+		Title:    pullRequest.Title,
+		Draft:    pullRequest.Draft,
+	}, options)
+}
+
+// Warning about commits on the branch which are not pushed, as the PR doesn't
+// include them yet. Empty if there are none.
+func unpushedWarning(repository *git.Repository, remoteName string, branch string, remoteBranch string) string {
+	count, ok := unpushedCommits(repository, remoteName, branch, remoteBranch)
+	if !ok || count == 0 {
+		return ""
+	}
+
+	commits := "commits"
+	if count == 1 {
+		commits = "commit"
 	}
 
-	return nil, err
+	return fmt.Sprintf("Local branch has %d unpushed %s; the PR may not reflect your latest changes.", count, commits)
 }
 
-func openGitLab(branch string, projectPath string, print bool) {
-	gitlabToken := config.Get().GitLabToken
+// Look up PR for the branch in each remote, starting with the default one, and
+// open the first one found. Remotes pointing to the same project are checked once.
+// Remotes that can't be checked, e.g. without a token, are skipped.
+func openAllRemotes(stdout io.Writer, repository *git.Repository, branch string, options OpenOptions) {
+	remotes, err := repository.Remotes()
+	handleError(err, "Unable to list remotes")
+
+	sort.SliceStable(remotes, func(i, j int) bool {
+		return remotes[i].Config().Name == defaultRemote() && remotes[j].Config().Name != defaultRemote()
+	})
+
+	checked := map[string]bool{}
 
-	if gitlabToken == "" {
-		color.Red("GitLab token is not set. Run `pro auth gitlab` to set it.")
+	for _, remote := range remotes {
+		name := remote.Config().Name
+
+		hostName, projectPath, err := parseRemoteURL(preferredURL(repository, remote))
+		if err != nil {
+			debug.Printf("Skipping remote %s: %s", name, err)
+			continue
+		}
+
+		project := hostName + "/" + strings.ToLower(projectPath)
+		if checked[project] {
+			debug.Printf("Skipping remote %s, %s was already checked", name, project)
+			continue
+		}
+		checked[project] = true
+
+		host, ok := resolveHost(hostName)
+		if _, hasProvider := pullRequestProvider(host); !ok || !hasProvider {
+			debug.Printf("Skipping remote %s, pull requests can't be looked up on %s", name, hostName)
+			continue
+		}
+
+		if host.Token == "" {
+			color.Yellow("Skipping remote %s, token for %s is not set.", name, host.Name)
+			continue
+		}
+
+		printInfo("Checking remote %s\n", color.GreenString(name))
+
+		found, err := lookupPullRequests(host, branch, projectPath, options.Base)
+		if err != nil {
+			color.Yellow("Unable to get pull requests from remote %s: %s", name, err)
+			continue
+		}
+
+		pullRequest, ok := selectPullRequest(found, options.First || options.JSON)
+		if !ok {
+			continue
+		}
+
+		printInfo("Found pull request in remote %s\n", color.GreenString(name))
+
+		showResult(stdout, Result{
+			Branch:   branch,
+			URL:      pullRequest.URL,
+			Provider: host.Type,
+			Number:   pullRequest.Number,
+			Title:    pullRequest.Title,
+			Draft:    pullRequest.Draft,
+		}, options)
+		return
+	}
+
+	fmt.Println("No open pull request found for current branch in any remote")
+	os.Exit(exitNoPullRequest)
+}
+
+// Custom hosts have no API to look up pull requests, so URLs are only
+// rendered from the templates in the config
+func openCustomURL(stdout io.Writer, host remoteHost, key string, template string, projectPath string, branch string, number int, options OpenOptions) {
+	if template == "" {
+		color.Red("Set %s of %s in the config file.", key, host.Name)
 		os.Exit(1)
 	}
 
-	mergeRequest, err := gitlab.FindMergeRequest(projectPath, gitlabToken, branch)
-	if err != nil {
-		if errors.Is(err, gitlab.ErrNotFound) {
-			fmt.Println("No open merge request found for current branch")
-			fmt.Println("Create pull request at", color.BlueString("https://gitlab.com/%s/merge_requests/new?merge_request%%5Bsource_branch%%5D=%s", projectPath, branch))
-			os.Exit(0)
-		} else if errors.Is(err, gitlab.ErrUnauthorized) || errors.Is(err, gitlab.ErrTokenExpired) {
-			color.Red("Unable to get merge requests: %s", err.Error())
-			fmt.Println("Connect GitLab again with `pro auth gitlab`.")
-			os.Exit(1)
-		} else {
-			color.Red("Unable to get merge requests: %s", err.Error())
-			os.Exit(1)
+	showResult(stdout, Result{
+		Branch:   branch,
+		URL:      renderURL(template, host.Name, projectPath, branch, number),
+		Provider: host.Type,
+		Number:   number,
+	}, options)
+}
+
+// Open pull request with given number, checking it exists first
+func openNumber(stdout io.Writer, remoteURL string, options OpenOptions) {
+	host, projectPath := resolveRemoteURL(remoteURL)
+
+	if host.Type == "custom" {
+		hostConfig, _ := config.Get().FindHost(host.Name)
+		openCustomURL(stdout, host, "pr_url", hostConfig.PRURL, projectPath, "", options.Number, options)
+		return
+	}
+
+	// Gerrit allows anonymous access
+	if host.Token == "" && host.Type != "gerrit" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	pullRequest, err := getPullRequest(host, projectPath, options.Number)
+	if isNotFoundError(err) {
+		color.Red("Pull request #%d not found in %s.", options.Number, projectPath)
+		os.Exit(exitNoPullRequest)
+	}
+	handleProviderError(host, err, "Unable to get pull request")
+
+	showResult(stdout, Result{
+		URL:      pullRequest.URL,
+		Provider: host.Type,
+		Number:   pullRequest.Number,
+		Title:    pullRequest.Title,
+		Draft:    pullRequest.Draft,
+	}, options)
+}
+
+// Whether err is a not found error of any provider
+func isNotFoundError(err error) bool {
+	return errors.Is(err, providers.ErrNotFound)
+}
+
+// Get pull request by number
+func getPullRequest(host remoteHost, projectPath string, number int) (pullRequestInfo, error) {
+	switch host.Type {
+	case "github":
+		pullRequest, err := github.PullRequest(host.API, projectPath, host.Token, number)
+		return pullRequestInfo{Number: pullRequest.Number, Title: pullRequest.Title, URL: pullRequest.HtmlURL, Draft: pullRequest.Draft,
+			HeadBranch: pullRequest.Head.Ref}, err
+	case "gitlab":
+		mergeRequest, err := gitlab.MergeRequest(host.API, projectPath, host.Token, number)
+		return pullRequestInfo{Number: mergeRequest.IID, Title: mergeRequest.Title, URL: mergeRequest.WebUrl, Draft: mergeRequest.IsDraft(),
+			HeadBranch: mergeRequest.SourceBranch}, err
+	case "bitbucket":
+		pullRequest, err := bitbucket.PullRequest(projectPath, host.Token, number)
+		info := pullRequestInfo{Number: pullRequest.ID, Title: pullRequest.Title, URL: pullRequest.Links.Html.Href, Draft: pullRequest.Draft,
+			HeadBranch: pullRequest.Source.Branch.Name}
+		if fork := pullRequest.Source.Repository.FullName; fork != "" && !strings.EqualFold(fork, projectPath) {
+			info.HeadCloneURL = pullRequest.SourceCloneURL()
 		}
+
+		return info, err
+	case "gitea":
+		pullRequest, err := gitea.PullRequest(host.API, projectPath, host.Token, number)
+		return pullRequestInfo{Number: pullRequest.Number, Title: pullRequest.Title, URL: pullRequest.HtmlURL, Draft: pullRequest.IsDraft(),
+			HeadBranch: pullRequest.Head.Ref}, err
+	case "gerrit":
+		change, err := gerrit.Change(host.API, host.Token, number)
+		return pullRequestInfo{Number: change.Number, Title: change.Subject, URL: change.URL(host.API), Draft: change.WorkInProgress}, err
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		pullRequest, err := azure.PullRequest(repository, host.Token, number)
+		info := pullRequestInfo{Number: pullRequest.ID, Title: pullRequest.Title, URL: pullRequest.WebURL, Draft: pullRequest.IsDraft,
+			HeadBranch: pullRequest.SourceBranch()}
+		if pullRequest.ForkSource != nil {
+			info.HeadCloneURL = pullRequest.ForkSource.Repository.RemoteURL
+		}
+
+		return info, err
+	default:
+		return pullRequestInfo{}, errors.New("unknown remote type")
 	}
+}
 
-	url := mergeRequest.WebUrl
+// Print result as JSON or show its URL
+func showResult(stdout io.Writer, result Result, options OpenOptions) {
+	if result.Draft {
+		color.Yellow("Pull request is a draft.")
+	}
 
-	if print {
-		color.Blue(url)
-	} else {
-		fmt.Println("Opening " + color.BlueString(url))
-		openBrowser(url)
+	if options.Files && result.Number != 0 {
+		result.URL = filesURL(result.Provider, result.URL)
+	}
+
+	if options.Format != "" && !options.JSON {
+		text := formatResult(result, options.Format)
+		fmt.Fprintln(results(), text)
+
+		if options.Copy {
+			err := copyToClipboard(text)
+			handleError(err, "Unable to copy to clipboard")
+		}
+
+		return
+	}
+
+	if !options.JSON {
+		if !options.Print && !options.Copy && result.Number != 0 && result.Title != "" {
+			printInfo("Opening %s: %s\n", color.New(color.Bold).Sprintf("#%d", result.Number), result.Title)
+		}
+
+		showURL(result.URL, options.OutputOptions)
+		return
+	}
+
+	printJSON(stdout, result)
+
+	if options.Copy {
+		err := copyToClipboard(result.URL)
+		handleError(err, "Unable to copy to clipboard")
 	}
 }
 
-func openGitHub(branch string, projectPath string, print bool) {
-	githubToken := config.Get().GitHubToken
+// Open every pull request found for the branch, asking first if there are
+// more than maxTabs of them
+func openAllPullRequests(stdout io.Writer, host remoteHost, branch string, pullRequests []pullRequestInfo, options OpenOptions) {
+	var results []Result
+	for _, pullRequest := range pullRequests {
+		results = append(results, Result{
+			Branch:   branch,
+			URL:      pullRequest.URL,
+			Provider: host.Type,
+			Number:   pullRequest.Number,
+			Title:    pullRequest.Title,
+			Draft:    pullRequest.Draft,
+		})
+	}
+
+	if options.JSON {
+		printJSON(stdout, results)
+		return
+	}
 
-	if githubToken == "" {
-		color.Red("GitHub token is not set. Run `pro auth github` to set it.")
+	if !options.Print && options.Format == "" && len(results) > maxTabs {
+		confirmTabs(len(results))
+	}
+
+	for _, result := range results {
+		showResult(stdout, result, options)
+	}
+}
+
+// Ask before opening many browser tabs at once
+func confirmTabs(count int) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		color.Red("Found %d pull requests, use --print to list them instead of opening %d tabs.", count, count)
+		os.Exit(1)
+	}
+
+	answer := readLine(fmt.Sprintf("Found %d pull requests. Open all of them? [y/N]: ", count))
+	if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+		fmt.Println("Nothing opened")
 		os.Exit(1)
 	}
+}
+
+// Render result with a template given with --format, or a preset name
+func formatResult(result Result, format string) string {
+	if preset, ok := formatPresets[format]; ok {
+		format = preset
+	}
 
-	pullRequest, err := github.FindPullRequest(projectPath, githubToken, branch)
+	tmpl, err := template.New("format").Parse(format)
 	if err != nil {
-		if errors.Is(err, github.ErrNotFound) {
-			fmt.Println("No open pull request found for current branch")
-			fmt.Println("Create pull request at", color.BlueString("https://github.com/%s/pull/new/%s", projectPath, branch))
-			os.Exit(0)
-		} else if errors.Is(err, github.ErrUnauthorized) {
-			color.Red("Unable to get pull requests: %s", err.Error())
-			fmt.Println("Token may be expired or deleted. Run `pro auth github` to connect GitHub again.")
-			os.Exit(1)
-		} else {
-			color.Red("Unable to get pull requests: %s", err.Error())
-			os.Exit(1)
-		}
+		color.Red("Invalid format: %s", err)
+		os.Exit(1)
 	}
 
-	url := pullRequest.HtmlURL
+	var text strings.Builder
+	err = tmpl.Execute(&text, result)
+	handleError(err, "Unable to format result")
 
-	if print {
-		color.Blue(url)
-	} else {
-		fmt.Println("Opening " + color.BlueString(url))
-		openBrowser(url)
+	return text.String()
+}
+
+// Branch of a fork as referenced in upstream pull requests. GitHub needs the
+// fork owner to match the head branch.
+func forkBranch(host remoteHost, forkPath string, branch string) string {
+	if host.Type == "github" {
+		return strings.Split(forkPath, "/")[0] + ":" + branch
+	}
+
+	return branch
+}
+
+// Pull request details common for all providers
+type pullRequestInfo struct {
+	Number  int
+	Title   string
+	URL     string
+	Draft   bool
+	Author  string
+	HeadSHA string
+	// Branch the PR is opened from and clone URL of its repository if it's a fork.
+	// Only set when getting a single PR.
+	HeadBranch   string
+	HeadCloneURL string
+	// CI status page, empty if provider doesn't have one
+	ChecksURL string
+}
+
+// Find open pull request for given branch. Returns false if no open pull request was found.
+// If there are more, the first one returned by the provider is used.
+func findPullRequest(host remoteHost, branch string, projectPath string) (pullRequestInfo, bool) {
+	pullRequests := findPullRequests(host, branch, projectPath, "")
+	if len(pullRequests) == 0 {
+		return pullRequestInfo{}, false
+	}
+
+	return pullRequests[0], true
+}
+
+// Find all open pull requests for given branch, e.g. targeting different base branches.
+// Non-empty base limits them to those targeting that branch.
+func findPullRequests(host remoteHost, branch string, projectPath string, base string) []pullRequestInfo {
+	pullRequests, err := lookupPullRequests(host, branch, projectPath, base)
+	handleLookupError(host, projectPath, err)
+
+	return pullRequests
+}
+
+// Exit with a helpful message if pull requests can't be looked up
+func handleLookupError(host remoteHost, projectPath string, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownHost):
+		exitUnknownRemote()
+	case errors.Is(err, ErrNoToken):
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		fmt.Println("Or use `pro --web-login` to open pull requests of the branch in the browser without a token.")
+		os.Exit(exitAuthError)
+	case errors.Is(err, providers.ErrRepositoryNotFound):
+		color.Red("Repository %s not found on %s.", projectPath, host.Name)
+		fmt.Println("Make sure it exists and the token has access to it.")
+		os.Exit(1)
 	}
+	handleProviderError(host, err, "Unable to get pull requests")
 }
 
-func openBrowser(url string) {
-	var err error
+// Same as findPullRequests, returning errors instead of exiting
+func lookupPullRequests(host remoteHost, branch string, projectPath string, base string) ([]pullRequestInfo, error) {
+	provider, ok := pullRequestProvider(host)
+	if !ok {
+		return nil, ErrUnknownHost
+	}
+
+	if host.Token == "" {
+		return nil, ErrNoToken
+	}
+
+	found, err := provider.FindPullRequests(projectPath, host.Token, branch, base)
+	if errors.Is(err, providers.ErrNotFound) {
+		return nil, nil
+	}
+
+	return pullRequestInfos(found), err
+}
 
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
+// Provider to look pull requests up in, false if the host type has none
+func pullRequestProvider(host remoteHost) (providers.Provider, bool) {
+	switch host.Type {
+	case "github":
+		return github.Provider{BaseURL: host.API}, true
+	case "gitlab":
+		return gitlab.Provider{BaseURL: host.API}, true
+	case "bitbucket":
+		return bitbucket.Provider{}, true
+	case "gitea":
+		return gitea.Provider{BaseURL: host.API}, true
+	case "azure":
+		return azure.Provider{Host: host.Name}, true
 	default:
-		err = fmt.Errorf("unsupported platform")
+		return nil, false
 	}
+}
 
-	if err != nil {
-		fmt.Printf("Unable to open browser: %s\n", err)
+// Convert pull requests returned by a provider
+func pullRequestInfos(found []providers.PullRequest) []pullRequestInfo {
+	var pullRequests []pullRequestInfo
+	for _, pullRequest := range found {
+		pullRequests = append(pullRequests, pullRequestInfo{
+			Number:    pullRequest.Number,
+			Title:     pullRequest.Title,
+			URL:       pullRequest.URL,
+			Draft:     pullRequest.Draft,
+			Author:    pullRequest.Author,
+			HeadSHA:   pullRequest.HeadSHA,
+			ChecksURL: pullRequest.ChecksURL,
+		})
+	}
+
+	return pullRequests
+}
+
+// Exit with the time when GitHub API can be used again if it's rate limited
+func exitIfRateLimited(err error) {
+	var rateLimit github.RateLimitError
+	if !errors.As(err, &rateLimit) {
+		return
+	}
+
+	color.Red("GitHub API rate limit exceeded.")
+	if !rateLimit.Reset.IsZero() {
+		fmt.Printf("Try again after %s.\n", rateLimit.Reset.Local().Format("15:04:05"))
+	}
+
+	os.Exit(1)
+}
+
+// Pick one of pull requests found for a branch. More of them can be open from
+// the same branch, e.g. against different base branches. The user is asked to
+// choose unless first is set or the output is not interactive.
+func selectPullRequest(pullRequests []pullRequestInfo, first bool) (pullRequestInfo, bool) {
+	switch {
+	case len(pullRequests) == 0:
+		return pullRequestInfo{}, false
+	case len(pullRequests) == 1:
+		return pullRequests[0], true
+	case first || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())):
+		color.Yellow("Found %d pull requests for the branch, using #%d. Use `pro <number>` to open another one.", len(pullRequests), pullRequests[0].Number)
+		return pullRequests[0], true
+	}
+
+	fmt.Printf("Found %d pull requests for the branch:\n", len(pullRequests))
+	for i, pullRequest := range pullRequests {
+		fmt.Printf("%3d) #%d %s %s\n", i+1, pullRequest.Number, pullRequest.Title, color.CyanString(pullRequest.Author))
+	}
+
+	choice, err := strconv.Atoi(readLine(fmt.Sprintf("Select pull request [1-%d]: ", len(pullRequests))))
+	if err != nil || choice < 1 || choice > len(pullRequests) {
+		color.Red("Invalid choice.")
 		os.Exit(1)
 	}
+
+	return pullRequests[choice-1], true
 }
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+)
+
+// How the resulting URL is presented
+type OutputOptions struct {
+	// Print URL instead of opening it in browser
+	Print bool
+	// Copy URL to clipboard instead of opening it in browser
+	Copy bool
+	// Browser command template, see openBrowser
+	Browser string
+	// Open URL in a private browsing window
+	Private bool
+}
+
+// Print URL, copy it to clipboard or open it in browser
+func showURL(url string, options OutputOptions) {
+	if options.Print {
+		fmt.Fprintln(results(), color.BlueString(url))
+	}
+
+	if options.Copy {
+		err := copyToClipboard(url)
+		handleError(err, "Unable to copy to clipboard")
+
+		if !options.Print {
+			printInfo("Copied %s to clipboard\n", color.BlueString(url))
+		}
+	}
+
+	if !options.Print && !options.Copy {
+		printInfo("Opening %s\n", color.BlueString(url))
+
+		if options.Private {
+			openPrivateBrowser(url, options.Browser)
+		} else {
+			openBrowser(url, options.Browser)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+type PipelineOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	OutputOptions
+}
+
+// Open CI status page of the current branch's pull request
+func Pipeline(repoPath string, options PipelineOptions) {
+	project := resolveProject(repoPath, options.Remote)
+
+	if project.host.Type != "github" && project.host.Type != "gitlab" {
+		color.Red("CI status page is not supported for %s yet.", project.host.Name)
+		os.Exit(1)
+	}
+
+	pullRequest, found := findPullRequest(project.host, project.branch, project.path)
+	if found {
+		fmt.Printf("Pull request #%d: %s\n", pullRequest.Number, pullRequest.Title)
+		showURL(pullRequest.ChecksURL, options.OutputOptions)
+		return
+	}
+
+	// Without a pull request fall back to CI status of the last commit
+	head := repositoryHead(project.repository)
+
+	fmt.Println("No open pull request found for current branch. Opening CI status of the last commit.")
+	showURL(commitChecksURL(project.host, project.path, head.Hash().String()), options.OutputOptions)
+}
+
+// URL of the CI status page for given commit
+func commitChecksURL(host remoteHost, projectPath string, sha string) string {
+	switch host.Type {
+	case "gitlab":
+		return fmt.Sprintf("https://%s/%s/-/commit/%s/pipelines", host.Name, projectPath, sha)
+	default:
+		return fmt.Sprintf("https://%s/%s/commit/%s/checks", host.Name, projectPath, sha)
+	}
+}
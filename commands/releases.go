@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type ReleasesOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Open the latest release instead of the list
+	Latest bool
+	OutputOptions
+}
+
+// Open releases page of the repository, or the latest release
+func Releases(repoPath string, options ReleasesOptions) {
+	repository := openRepo(repoPath)
+
+	host, projectPath := resolveRemoteHost(repository, options.Remote)
+
+	if !options.Latest {
+		showURL(releasesURL(host, projectPath), options.OutputOptions)
+		return
+	}
+
+	if host.Type != "github" && host.Type != "gitlab" && host.Type != "gitea" {
+		color.Red("%s doesn't have releases.", host.Name)
+		fmt.Println("Tags are available at", color.BlueString(releasesURL(host, projectPath)))
+		os.Exit(1)
+	}
+
+	if host.Token == "" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	tag, url, err := latestRelease(host, projectPath)
+	if errors.Is(err, providers.ErrNotFound) {
+		color.Red("No releases found in %s.", projectPath)
+		os.Exit(1)
+	}
+	handleProviderError(host, err, "Unable to get latest release")
+
+	fmt.Printf("Latest release: %s\n", color.GreenString(tag))
+
+	showURL(url, options.OutputOptions)
+}
+
+// URL of the release list. Providers without releases get the tag list instead.
+func releasesURL(host remoteHost, projectPath string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		return home + "/-/releases"
+	case "bitbucket":
+		return home + "/downloads/?tab=tags"
+	case "sourcehut":
+		return home + "/refs"
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return repository.WebURL() + "/tags"
+	default:
+		return home + "/releases"
+	}
+}
+
+// Tag name and web URL of the latest release
+func latestRelease(host remoteHost, projectPath string) (string, string, error) {
+	switch host.Type {
+	case "github":
+		release, err := github.LatestRelease(host.API, projectPath, host.Token)
+		return release.TagName, release.HtmlURL, err
+	case "gitlab":
+		release, err := gitlab.LatestRelease(host.API, projectPath, host.Token)
+		return release.TagName, release.Links.Self, err
+	default:
+		release, err := gitea.LatestRelease(host.API, projectPath, host.Token)
+		return release.TagName, release.HtmlURL, err
+	}
+}
@@ -0,0 +1,655 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/kevinburke/ssh_config"
+	giturls "github.com/whilp/git-urls"
+)
+
+// Find git repository in given directory or parent directories
+func findRepo(path string) (*git.Repository, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := plainOpen(absolutePath)
+
+	if err == nil {
+		return repository, nil
+	}
+
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		// Base case - we've reached the root of the filesystem
+		if isFilesystemRoot(absolutePath, filepath.VolumeName) {
+			return nil, ErrNoRepository
+		}
+
+		// Recurse to parent directory
+		return findRepo(filepath.Dir(absolutePath))
+	}
+
+	return nil, err
+}
+
+// Check if absolute path is the root of the filesystem, like "/", or of a
+// Windows drive, like "C:\". Volume name function is passed in, so Windows
+// paths can be checked on other systems too.
+func isFilesystemRoot(path string, volumeName func(string) string) bool {
+	rest := path[len(volumeName(path)):]
+	return rest == "" || rest == "/" || rest == `\`
+}
+
+// Open repository at given path. In linked worktrees .git is a file pointing to
+// .git/worktrees/<name> of the main repository, which only holds HEAD, while
+// refs and remotes are read from the "commondir" it links to.
+func plainOpen(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+}
+
+// Find git repository or exit with a helpful message
+func openRepo(path string) *git.Repository {
+	repository, err := findRepository(path)
+	if err != nil && !errors.Is(err, ErrNoRepository) {
+		handleError(err, "Unable to open repository")
+	}
+	if err != nil {
+		color.Red("Unable to find git repository in given directory or any of parent directories.")
+		fmt.Println("Please make sure you are in the project directory.")
+		os.Exit(1)
+	}
+
+	return repository
+}
+
+// Find git repository and load its .pro.yml. For the current directory
+// GIT_DIR and GIT_WORK_TREE environment variables are respected like in git.
+func findRepository(path string) (*git.Repository, error) {
+	var repository *git.Repository
+	var err error
+
+	gitDir := os.Getenv("GIT_DIR")
+	workTree := os.Getenv("GIT_WORK_TREE")
+
+	switch {
+	case path == "." && gitDir != "":
+		// Only refs and remotes are read, so the .git directory is enough
+		repository, err = plainOpen(gitDir)
+	case path == "." && workTree != "":
+		repository, err = findRepo(workTree)
+	default:
+		repository, err = findRepo(path)
+	}
+
+	if err != nil && !errors.Is(err, ErrNoRepository) {
+		return nil, fmt.Errorf("%w: %s", ErrNoRepository, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Bare repositories have no root to keep .pro.yml in
+	if worktree, err := repository.Worktree(); err == nil {
+		err = config.LoadRepo(worktree.Filesystem.Root())
+		if err != nil {
+			return nil, fmt.Errorf("unable to load %s: %w", config.RepoConfigFile, err)
+		}
+	}
+
+	return repository, nil
+}
+
+// Remote used when none is given, "origin" unless configured otherwise
+func defaultRemote() string {
+	if remote := config.Current().Remote; remote != "" {
+		return remote
+	}
+
+	return "origin"
+}
+
+// Get remote by name or exit listing available remotes
+func getRemote(repository *git.Repository, name string) *git.Remote {
+	remote, err := repository.Remote(name)
+	if err == nil {
+		return remote
+	}
+
+	color.Red("No remote named \"%s\" found.", name)
+
+	remotes, err := repository.Remotes()
+	handleError(err, "Unable to list remotes")
+
+	if len(remotes) == 0 {
+		fmt.Println("Repository has no remotes. Add one with `git remote add`.")
+	} else {
+		fmt.Println("Available remotes:")
+		for _, r := range remotes {
+			fmt.Printf("  %s\t%s\n", r.Config().Name, r.Config().URLs[0])
+		}
+		fmt.Println("Pick one with `--remote <name>`.")
+	}
+
+	os.Exit(1)
+	return nil
+}
+
+// URL of the remote to look up the project for. A push URL is preferred, as
+// branches are pushed there. Of several fetch URLs, the first one on a known
+// host is used.
+func preferredURL(repository *git.Repository, remote *git.Remote) string {
+	urls := remote.Config().URLs
+
+	if repoConfig, err := repository.Config(); err == nil {
+		pushURL := repoConfig.Raw.Section("remote").Subsection(remote.Config().Name).Option("pushurl")
+		if pushURL != "" {
+			debug.Printf("Using push URL %s of remote %s", pushURL, remote.Config().Name)
+			return pushURL
+		}
+	}
+
+	if len(urls) > 1 {
+		for _, url := range urls {
+			if hostName, _, err := parseRemoteURL(url); err == nil {
+				if _, ok := lookupHost(hostName); ok {
+					return url
+				}
+			}
+		}
+	}
+
+	return urls[0]
+}
+
+// Get HEAD reference or exit. In a repository without commits HEAD points
+// to a branch that doesn't exist yet.
+func repositoryHead(repository *git.Repository) *plumbing.Reference {
+	head, err := repository.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		color.Red("This repository has no commits yet.")
+		fmt.Println("Make the first commit and push it before opening a pull request.")
+		os.Exit(1)
+	}
+	handleError(err, "Unable to get repository head")
+
+	return head
+}
+
+// Get name of the checked out branch or exit
+func currentBranch(repository *git.Repository) string {
+	branch, detached, err := checkedOutBranch(repository)
+	handleBranchError(err)
+	handleError(err, "Unable to get current branch")
+
+	if detached {
+		printInfo("HEAD is detached, using branch %s pointing at the same commit\n", color.GreenString(branch))
+	}
+
+	return branch
+}
+
+// Exit with a helpful message if the checked out branch can't be found
+func handleBranchError(err error) {
+	switch {
+	case errors.Is(err, ErrBareRepository):
+		color.Red("This is a bare repository, it has no working branch.")
+		fmt.Println("Choose a branch with `pro open --branch <name>` or open a PR with `pro <number>`.")
+		os.Exit(1)
+	case errors.Is(err, ErrNoCommits):
+		color.Red("This repository has no commits yet.")
+		fmt.Println("Make the first commit and push it before opening a pull request.")
+		os.Exit(1)
+	case errors.Is(err, ErrNoBranch):
+		color.Red("No active branch found.")
+		fmt.Println("Switch to a branch and try again.")
+		os.Exit(0)
+	}
+}
+
+// Get name of the checked out branch. When HEAD is detached (e.g. in CI), a branch
+// pointing at the same commit is used and detached is true.
+func checkedOutBranch(repository *git.Repository) (branch string, detached bool, err error) {
+	// HEAD of a bare repository or mirror is only the default branch
+	if isBare(repository) {
+		return "", false, ErrBareRepository
+	}
+
+	head, err := repository.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", false, ErrNoCommits
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if head.Name().IsBranch() {
+		return head.Name().Short(), false, nil
+	}
+
+	branch, ok := branchAtCommit(repository, head.Hash())
+	if !ok {
+		return "", true, ErrNoBranch
+	}
+
+	return branch, true, nil
+}
+
+// Check core.bare instead of looking for a worktree, as a repository opened
+// with GIT_DIR has no worktree either
+func isBare(repository *git.Repository) bool {
+	repoConfig, err := repository.Config()
+	return err == nil && repoConfig.Core.IsBare
+}
+
+// Check if branch should open the repository home page. Branches from
+// main_branches config are used if set, otherwise the default branch of the
+// remote. Common main branch names are used if the default branch is unknown.
+func isMainBranch(repository *git.Repository, remoteName string, host remoteHost, projectPath string, branch string) bool {
+	conf := config.Current()
+	if len(conf.MainBranches) > 0 {
+		return conf.IsMainBranch(branch)
+	}
+
+	defaultBranch := remoteDefaultBranch(repository, remoteName, host, projectPath)
+	if defaultBranch == "" {
+		return conf.IsMainBranch(branch)
+	}
+
+	return branch == defaultBranch
+}
+
+// Default branch of the remote from refs/remotes/<remote>/HEAD or the API,
+// empty if it's unknown
+func remoteDefaultBranch(repository *git.Repository, remoteName string, host remoteHost, projectPath string) string {
+	if defaultBranch := remoteHEADBranch(repository, remoteName); defaultBranch != "" {
+		return defaultBranch
+	}
+
+	return apiDefaultBranch(host, projectPath)
+}
+
+// Default branch from refs/remotes/<remote>/HEAD, set by git clone. Empty if
+// the remote was added to an existing repository.
+func remoteHEADBranch(repository *git.Repository, remoteName string) string {
+	head, err := repository.Reference(plumbing.NewRemoteHEADReferenceName(remoteName), false)
+	if err != nil || head.Type() != plumbing.SymbolicReference {
+		return ""
+	}
+
+	prefix := "refs/remotes/" + remoteName + "/"
+	if !strings.HasPrefix(head.Target().String(), prefix) {
+		return ""
+	}
+
+	debug.Printf("Default branch from %s: %s", head.Name(), head.Target())
+
+	return strings.TrimPrefix(head.Target().String(), prefix)
+}
+
+// Default branch from GitHub or GitLab API, empty if it can't be looked up
+func apiDefaultBranch(host remoteHost, projectPath string) string {
+	if host.Token == "" {
+		return ""
+	}
+
+	var defaultBranch string
+	var err error
+
+	switch host.Type {
+	case "github":
+		var repository github.RepositoryResponse
+		repository, err = github.Repository(host.API, projectPath, host.Token)
+		defaultBranch = repository.DefaultBranch
+	case "gitlab":
+		var project gitlab.ProjectResponse
+		project, err = gitlab.Project(host.API, projectPath, host.Token)
+		defaultBranch = project.DefaultBranch
+	default:
+		return ""
+	}
+
+	if err != nil {
+		debug.Printf("Unable to get default branch: %s", err)
+		return ""
+	}
+
+	debug.Printf("Default branch from API: %s", defaultBranch)
+
+	return defaultBranch
+}
+
+// Print a warning if branch doesn't exist in the local repository.
+// The branch may still exist on the remote, so it's not an error.
+func warnIfNoLocalBranch(repository *git.Repository, branch string) {
+	if warning := noLocalBranchWarning(repository, branch); warning != "" {
+		color.Yellow("%s", warning)
+	}
+}
+
+// Warning printed by warnIfNoLocalBranch, empty if the branch exists
+func noLocalBranchWarning(repository *git.Repository, branch string) string {
+	_, err := repository.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return fmt.Sprintf("Branch \"%s\" doesn't exist locally, looking it up on the remote anyway.", branch)
+	}
+
+	return ""
+}
+
+// Find branch pointing at given commit. Local branches are preferred over
+// remote-tracking ones, names are sorted to make the choice deterministic.
+func branchAtCommit(repository *git.Repository, hash plumbing.Hash) (string, bool) {
+	references, err := repository.References()
+	if err != nil {
+		return "", false
+	}
+
+	var localBranches, remoteBranches []string
+
+	_ = references.ForEach(func(reference *plumbing.Reference) error {
+		if reference.Type() != plumbing.HashReference || reference.Hash() != hash {
+			return nil
+		}
+
+		name := reference.Name()
+
+		if name.IsBranch() {
+			localBranches = append(localBranches, name.Short())
+		} else if name.IsRemote() {
+			// refs/remotes/origin/feature -> feature
+			parts := strings.SplitN(name.Short(), "/", 2)
+			if len(parts) == 2 && parts[1] != "HEAD" {
+				remoteBranches = append(remoteBranches, parts[1])
+			}
+		}
+
+		return nil
+	})
+
+	sort.Strings(localBranches)
+	sort.Strings(remoteBranches)
+
+	if len(localBranches) > 0 {
+		return localBranches[0], true
+	}
+
+	if len(remoteBranches) > 0 {
+		return remoteBranches[0], true
+	}
+
+	return "", false
+}
+
+// Get name of the tag HEAD is detached at, when no branch points at the same
+// commit. Both lightweight and annotated tags are matched.
+func checkedOutTag(repository *git.Repository) (string, bool) {
+	head, err := repository.Head()
+	if err != nil || head.Name().IsBranch() || isBare(repository) {
+		return "", false
+	}
+
+	if _, ok := branchAtCommit(repository, head.Hash()); ok {
+		return "", false
+	}
+
+	tags, err := repository.Tags()
+	if err != nil {
+		return "", false
+	}
+
+	var names []string
+
+	_ = tags.ForEach(func(reference *plumbing.Reference) error {
+		hash := reference.Hash()
+
+		// Annotated tag references point at the tag object, not the commit
+		if tag, err := repository.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+
+		if hash == head.Hash() {
+			names = append(names, reference.Name().Short())
+		}
+
+		return nil
+	})
+
+	if len(names) == 0 {
+		return "", false
+	}
+
+	sort.Strings(names)
+
+	return names[0], true
+}
+
+// Version of parseRemoteURL results. Bump it when they change, so hosts and
+// paths cached by parseCachedRemoteURL are resolved again.
+const remoteURLParserVersion = 2
+
+var scpURLWithTilde = regexp.MustCompile(`^([a-zA-Z0-9_.-]+@)?([a-zA-Z0-9._-]+):(~.*)$`)
+
+// Parse remote URL into host name and project path (e.g. "github.com" and "wowu/pro")
+func parseRemoteURL(remoteURL string) (string, string, error) {
+	// Scheme variants of SSH, not all of them are recognized by git-urls
+	for _, scheme := range []string{"git+ssh://", "ssh+git://"} {
+		if strings.HasPrefix(remoteURL, scheme) {
+			remoteURL = "ssh://" + strings.TrimPrefix(remoteURL, scheme)
+		}
+	}
+
+	// git-urls doesn't accept "~" in scp-like paths, used by SourceHut (git@git.sr.ht:~user/repo)
+	if match := scpURLWithTilde.FindStringSubmatch(remoteURL); match != nil {
+		remoteURL = "ssh://" + match[1] + match[2] + "/" + match[3]
+	}
+
+	gitURL, err := giturls.Parse(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Path can have any number of segments, e.g. GitLab subgroups "group/subgroup/project"
+	projectPath := normalizeProjectPath(gitURL.Path)
+
+	host := strings.ToLower(gitURL.Host)
+	switch gitURL.Scheme {
+	case "ssh":
+		// SSH port is unrelated to the web host
+		host = resolveSSHAlias(strings.ToLower(gitURL.Hostname()))
+	case "git":
+		// Read-only git:// protocol, its port (9418 by default) is unrelated to the web host
+		host = strings.ToLower(gitURL.Hostname())
+	case "http", "https":
+		// Non-default port is kept, as the web interface is served on it as well
+		if port := gitURL.Port(); (gitURL.Scheme == "https" && port == "443") || (gitURL.Scheme == "http" && port == "80") {
+			host = strings.ToLower(gitURL.Hostname())
+		}
+	}
+
+	debug.Printf("Remote URL %s parsed as host %s, path %s", remoteURL, host, projectPath)
+
+	return host, projectPath, nil
+}
+
+// Clean project path of a remote URL, so it's the same however the URL is
+// written: empty segments and trailing slashes are dropped, as well as the
+// ".git" suffix, e.g. "/Org//Repo.git/" becomes "Org/Repo". Case is kept, as
+// paths are case-sensitive on some providers.
+func normalizeProjectPath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" && segment != "." {
+			segments = append(segments, segment)
+		}
+	}
+
+	// Remote pointing at the .git directory, e.g. "/srv/repo/.git"
+	if len(segments) > 1 && segments[len(segments)-1] == ".git" {
+		segments = segments[:len(segments)-1]
+	}
+
+	return strings.TrimSuffix(strings.Join(segments, "/"), ".git")
+}
+
+// Map SSH host alias from ~/.ssh/config (e.g. "gh-work") to the real host name
+func resolveSSHAlias(alias string) string {
+	hostName := ssh_config.Get(alias, "HostName")
+	if hostName == "" {
+		return alias
+	}
+
+	// %h token expands to the alias itself
+	hostName = strings.ReplaceAll(hostName, "%h", alias)
+	debug.Printf("SSH host alias %s resolved to %s", alias, hostName)
+
+	return hostName
+}
+
+// Subject line of the HEAD commit message
+func headCommitSubject(repository *git.Repository) string {
+	head := repositoryHead(repository)
+
+	commit, err := repository.CommitObject(head.Hash())
+	handleError(err, "Unable to read HEAD commit")
+
+	return strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+}
+
+// Current branch and remote repository a command operates on
+type project struct {
+	repository *git.Repository
+	// Name of the branch on the remote
+	branch string
+	host   remoteHost
+	path   string
+}
+
+// Resolve repository, current branch and remote host or exit.
+// Empty remote name means the default remote.
+func resolveProject(repoPath string, remoteName string) project {
+	repository := openRepo(repoPath)
+
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	remote := getRemote(repository, remoteName)
+
+	branch := currentBranch(repository)
+	printInfo("Current branch: %s\n", color.GreenString(branch))
+	branch = remoteBranch(repository, branch)
+
+	host, projectPath := resolveRemoteURL(preferredURL(repository, remote))
+
+	return project{repository, branch, host, projectPath}
+}
+
+// Resolve host and project path of the remote or exit.
+// Empty remote name means the default remote.
+func resolveRemoteHost(repository *git.Repository, remoteName string) (remoteHost, string) {
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	return resolveRemoteURL(preferredURL(repository, getRemote(repository, remoteName)))
+}
+
+// Resolve host and project path of the remote URL or exit
+func resolveRemoteURL(remoteURL string) (remoteHost, string) {
+	hostName, projectPath, err := parseRemoteURL(remoteURL)
+	handleError(err, "Unable to parse remote URL")
+
+	host, ok := resolveHost(hostName)
+	if !ok {
+		exitUnknownRemote()
+	}
+
+	return host, projectPath
+}
+
+// Exit when the remote doesn't point to a known hosting service
+func exitUnknownRemote() {
+	fmt.Println("Unknown remote type")
+	os.Exit(exitUnknownHost)
+}
+
+// Number of commits on the local branch which are not on its remote-tracking
+// branch, named remoteBranch on the remote. False if either of them doesn't
+// exist, e.g. the branch is not pushed.
+func unpushedCommits(repository *git.Repository, remoteName string, branch string, remoteBranch string) (int, bool) {
+	local, err := repository.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return 0, false
+	}
+
+	tracking, err := repository.Reference(plumbing.NewRemoteReferenceName(remoteName, remoteBranch), true)
+	if err != nil {
+		return 0, false
+	}
+
+	if local.Hash() == tracking.Hash() {
+		return 0, true
+	}
+
+	remoteCommit, err := repository.CommitObject(tracking.Hash())
+	if err != nil {
+		return 0, false
+	}
+
+	commits, err := repository.Log(&git.LogOptions{From: local.Hash()})
+	if err != nil {
+		return 0, false
+	}
+
+	count := 0
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if pushed, err := commit.IsAncestor(remoteCommit); err != nil || pushed {
+			return storer.ErrStop
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, false
+	}
+
+	debug.Printf("Branch %s has %d commits not pushed to %s", branch, count, remoteName)
+
+	return count, true
+}
+
+// Name of the branch on the remote, from its upstream set by `git push -u` or
+// `git branch --set-upstream-to`. The local name is used when no upstream is set.
+func pushedBranch(repository *git.Repository, branch string) string {
+	repoConfig, err := repository.Config()
+	if err != nil {
+		return branch
+	}
+
+	tracking, ok := repoConfig.Branches[branch]
+	if !ok || !tracking.Merge.IsBranch() {
+		return branch
+	}
+
+	debug.Printf("Upstream of branch %s: %s", branch, tracking.Merge)
+
+	return tracking.Merge.Short()
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wowu/pro/config"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestMain(m *testing.M) {
+	// Don't read tokens and hosts of the user running the tests
+	dir, err := os.MkdirTemp("", "pro-test")
+	if err != nil {
+		panic(err)
+	}
+
+	config.File = filepath.Join(dir, "config.yml")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		path string
+	}{
+		// GitLab subgroups, any number of levels deep
+		{"git@gitlab.com:group/sub/repo.git", "gitlab.com", "group/sub/repo"},
+		{"git@gitlab.com:group/sub/sub2/repo.git", "gitlab.com", "group/sub/sub2/repo"},
+		{"git@gitlab.com:group/sub/sub2/sub3/repo.git", "gitlab.com", "group/sub/sub2/sub3/repo"},
+		{"ssh://git@gitlab.com/group/sub/sub2/repo.git", "gitlab.com", "group/sub/sub2/repo"},
+		{"https://gitlab.com/group/sub/sub2/repo.git", "gitlab.com", "group/sub/sub2/repo"},
+		{"https://gitlab.com/group/sub/sub2/sub3/repo", "gitlab.com", "group/sub/sub2/sub3/repo"},
+
+		// SSH port, SSH scheme variants, trailing slashes and HTTPS ports
+		{"ssh://git@host:2222/owner/repo.git", "host", "owner/repo"},
+		{"ssh://git@github.com:22/owner/repo.git", "github.com", "owner/repo"},
+		{"git+ssh://git@github.com/owner/repo.git", "github.com", "owner/repo"},
+		{"ssh+git://git@github.com/owner/repo.git", "github.com", "owner/repo"},
+		{"git@github.com:owner/repo/", "github.com", "owner/repo"},
+		{"git@github.com:owner/repo.git/", "github.com", "owner/repo"},
+		{"https://gitea.example.com:3000/owner/repo.git", "gitea.example.com:3000", "owner/repo"},
+		{"https://github.com:443/owner/repo.git", "github.com", "owner/repo"},
+		{"http://gitea.example.com:80/owner/repo.git", "gitea.example.com", "owner/repo"},
+
+		// Read-only git protocol, its port is unrelated to the web host
+		{"git://github.com/owner/repo.git", "github.com", "owner/repo"},
+		{"git://git.example.com:9418/owner/repo.git", "git.example.com", "owner/repo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			host, path, err := parseRemoteURL(test.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if host != test.host || path != test.path {
+				t.Errorf("got host %q, path %q, want %q, %q", host, path, test.host, test.path)
+			}
+		})
+	}
+}
+
+func TestNormalizeProjectPath(t *testing.T) {
+	tests := []struct {
+		url     string
+		path    string
+		homeURL string
+	}{
+		{"https://github.com/Org/Repo/", "Org/Repo", "https://github.com/Org/Repo"},
+		{"https://github.com/Org/Repo.git/", "Org/Repo", "https://github.com/Org/Repo"},
+		{"https://github.com/org/repo.git", "org/repo", "https://github.com/org/repo"},
+		{"git@github.com:MixedCase/repo.git/", "MixedCase/repo", "https://github.com/MixedCase/repo"},
+		{"ssh://git@github.com//Org//Repo.git", "Org/Repo", "https://github.com/Org/Repo"},
+		{"git@github.com:org/repo/.git", "org/repo", "https://github.com/org/repo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			host, path, err := parseRemoteURL(test.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if path != test.path {
+				t.Errorf("got path %q, want %q", path, test.path)
+			}
+
+			if url := homeURL(host, path); url != test.homeURL {
+				t.Errorf("got home URL %q, want %q", url, test.homeURL)
+			}
+		})
+	}
+}
+
+func TestFindRepoStopsAtRoot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := findRepo(dir)
+	if err == nil {
+		t.Skip("temporary directory is inside a git repository")
+	}
+
+	if !errors.Is(err, ErrNoRepository) {
+		t.Errorf("got error %v, want %v", err, ErrNoRepository)
+	}
+}
+
+func TestIsFilesystemRoot(t *testing.T) {
+	// filepath.VolumeName as it works on Windows
+	windowsVolumeName := func(path string) string {
+		if len(path) >= 2 && path[1] == ':' {
+			return path[:2]
+		}
+
+		return ""
+	}
+
+	tests := []struct {
+		path       string
+		volumeName func(string) string
+		want       bool
+	}{
+		{`C:\`, windowsVolumeName, true},
+		{`C:`, windowsVolumeName, true},
+		{`C:\repo\sub`, windowsVolumeName, false},
+		{`C:\repo`, windowsVolumeName, false},
+		{"/", func(string) string { return "" }, true},
+		{"/repo/sub", func(string) string { return "" }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := isFilesystemRoot(test.path, test.volumeName); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPreferredURLUsesPushURL(t *testing.T) {
+	repository, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, err := repository.CreateRemote(&gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"https://github.com/owner/repo.git"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RemoteConfig has no push URL, it's only kept in the raw config
+	repoConfig, err := repository.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoConfig.Raw.Section("remote").Subsection("origin").SetOption("pushurl", "git@github.com:owner/repo.git")
+	if err := repository.SetConfig(repoConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if url := preferredURL(repository, remote); url != "git@github.com:owner/repo.git" {
+		t.Errorf("got %q, want push URL", url)
+	}
+}
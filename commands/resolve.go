@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+)
+
+// Errors returned by ResolvePRURL. Provider errors, like providers.ErrUnauthorized,
+// are returned as they are.
+var (
+	ErrNoRepository   = errors.New("no git repository found")
+	ErrNoRemote       = errors.New("remote not found")
+	ErrBareRepository = errors.New("bare repository has no working branch")
+	ErrNoCommits      = errors.New("repository has no commits")
+	ErrNoBranch       = errors.New("no active branch found")
+	ErrUnknownHost    = errors.New("unknown remote type")
+	ErrNoToken        = errors.New("token is not set")
+)
+
+type ResolveOptions struct {
+	// Name of the remote. When empty, default remote is used and the "upstream"
+	// remote is checked as well, like in Open.
+	Remote string
+	// Branch to look up PR for instead of the current one
+	Branch string
+	// Look up PR even on main branches
+	ForcePR bool
+	// Only match PRs targeting this branch
+	Base string
+	// Reuse and store results in the cache file like the command, see cache_ttl
+	Cache bool
+	// Don't warn about commits which are not pushed yet
+	NoPushCheck bool
+}
+
+// Pull request found for a branch. When there is none, URL is empty and
+// CreateURL points to the page for creating one.
+type Result struct {
+	Branch    string `json:"branch,omitempty"`
+	URL       string `json:"url,omitempty"`
+	CreateURL string `json:"create_url,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	Number    int    `json:"number,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Draft     bool   `json:"draft,omitempty"`
+	// Main branches resolve to the repository home page instead of a PR
+	Home bool `json:"-"`
+	// Problems which didn't stop the lookup, e.g. unpushed commits
+	Warnings []string `json:"-"`
+
+	// All pull requests found for the branch, the first one is described above
+	pullRequests []pullRequestInfo
+}
+
+// Find URL of the pull request for a branch like Open does, without printing,
+// prompting or exiting, for use in other Go programs. If more PRs match, the
+// first one is used. Gerrit changes are not looked up.
+func ResolvePRURL(repoPath string, options ResolveOptions) (Result, error) {
+	repository, err := findRepository(repoPath)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Settings are read throughout the lookup, exiting if the file is broken.
+	// The file is only read once, so checking it here is enough.
+	if _, err := config.Load(); err != nil {
+		return Result{}, fmt.Errorf("unable to load config file: %w", err)
+	}
+
+	target, err := findTarget(repository, options, reporter{})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return target.resolve(options)
+}
+
+// Receives messages of a lookup as they happen, so the command can show
+// progress. Nil functions drop the messages, warnings are still returned in
+// the result.
+type reporter struct {
+	info func(format string, a ...interface{})
+	warn func(message string)
+}
+
+// Branch and project a pull request is looked up for, shared by Open and
+// ResolvePRURL
+type target struct {
+	repository *git.Repository
+	remoteName string
+	// Look up PRs of a fork in the "upstream" remote as well
+	checkUpstream bool
+	remoteURL     string
+	// Checked out or given branch and its name on the remote
+	localBranch string
+	branch      string
+	detached    bool
+	hostName    string
+	projectPath string
+	host        remoteHost
+	// Whether the host is known or configured
+	known    bool
+	warnings []string
+	report   reporter
+}
+
+// Find remote, branch and host to look up a pull request for
+func findTarget(repository *git.Repository, options ResolveOptions, report reporter) (*target, error) {
+	t := &target{repository: repository, remoteName: options.Remote, report: report}
+
+	t.checkUpstream = t.remoteName == ""
+	if t.checkUpstream {
+		t.remoteName = defaultRemote()
+	}
+
+	remote, err := repository.Remote(t.remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoRemote, t.remoteName)
+	}
+
+	t.localBranch = options.Branch
+	if t.localBranch == "" {
+		t.localBranch, t.detached, err = checkedOutBranch(repository)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		t.warn(noLocalBranchWarning(repository, t.localBranch))
+	}
+
+	t.branch = pushedBranch(repository, t.localBranch)
+	t.remoteURL = preferredURL(repository, remote)
+
+	t.hostName, t.projectPath, err = parseCachedRemoteURL(repository, t.remoteName, t.remoteURL, options.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse remote URL: %w", err)
+	}
+
+	// Resolved once, as each call may create a GitHub App token
+	var warning string
+	t.host, t.known, warning, err = resolveHostWithWarning(t.hostName)
+	if err != nil {
+		return nil, err
+	}
+	t.warn(warning)
+
+	return t, nil
+}
+
+// Record a warning, empty ones are ignored. Warnings repeated while waiting
+// for a PR are recorded once.
+func (t *target) warn(message string) {
+	if message == "" {
+		return
+	}
+
+	for _, warning := range t.warnings {
+		if warning == message {
+			return
+		}
+	}
+
+	t.warnings = append(t.warnings, message)
+	if t.report.warn != nil {
+		t.report.warn(message)
+	}
+}
+
+func (t *target) info(format string, a ...interface{}) {
+	if t.report.info != nil {
+		t.report.info(format, a...)
+	}
+}
+
+// Look up pull requests of the target branch. Main branches resolve to the
+// home page, SourceHut to the branch log and custom hosts to the compare URL.
+func (t *target) resolve(options ResolveOptions) (Result, error) {
+	result := Result{Branch: t.branch, Provider: t.host.Type}
+
+	if !options.ForcePR && isMainBranch(t.repository, t.remoteName, t.host, t.projectPath, t.branch) {
+		result.URL = homeURL(t.hostName, t.projectPath)
+		result.Home = true
+		result.Warnings = t.warnings
+		return result, nil
+	}
+
+	if !t.known || t.host.Type == "gerrit" {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnknownHost, t.hostName)
+	}
+
+	switch t.host.Type {
+	case "sourcehut":
+		result.URL = fmt.Sprintf("%s/log/%s", homeURL(t.host.Name, t.projectPath), providers.EscapePath(t.branch))
+		result.Warnings = t.warnings
+		return result, nil
+	case "custom":
+		result.CreateURL = newPullRequestURL(t.host, t.projectPath, t.branch)
+		result.Warnings = t.warnings
+		return result, nil
+	}
+
+	if !options.NoPushCheck {
+		t.warn(unpushedWarning(t.repository, t.remoteName, t.localBranch, t.branch))
+	}
+
+	var pullRequests []pullRequestInfo
+
+	cache, useCache := t.pullRequestCache(options)
+	if useCache {
+		if pullRequest, ok := cache.get(); ok {
+			pullRequests = []pullRequestInfo{pullRequest}
+		}
+	}
+
+	if len(pullRequests) == 0 {
+		var err error
+		pullRequests, err = t.lookup(options.Base)
+		if err != nil {
+			return Result{}, err
+		}
+
+		// Open caches the one picked by the user if there are more
+		if useCache && len(pullRequests) == 1 {
+			cache.set(pullRequests[0])
+		}
+	}
+
+	result.Warnings = t.warnings
+	result.pullRequests = pullRequests
+
+	if len(pullRequests) == 0 {
+		result.CreateURL = newPullRequestURL(t.host, t.projectPath, t.branch)
+		return result, nil
+	}
+
+	pullRequest := pullRequests[0]
+	result.URL = pullRequest.URL
+	result.Number = pullRequest.Number
+	result.Title = pullRequest.Title
+	result.Draft = pullRequest.Draft
+
+	return result, nil
+}
+
+// Pull request cache of the target branch, false if it's not used
+func (t *target) pullRequestCache(options ResolveOptions) (pullRequestCache, bool) {
+	cache, ok := newPullRequestCache(t.repository, t.remoteURL, t.branch)
+	// Cached PR may target a different base branch
+	return cache, ok && options.Cache && options.Base == ""
+}
+
+// Find open pull requests of the branch. If there are none, pull requests from
+// a fork are looked up in the upstream project.
+func (t *target) lookup(base string) ([]pullRequestInfo, error) {
+	pullRequests, err := lookupPullRequests(t.host, t.branch, t.projectPath, base)
+	if err != nil || len(pullRequests) > 0 || !t.checkUpstream {
+		return pullRequests, err
+	}
+
+	return t.lookupUpstream(base)
+}
+
+// Look up pull requests in the "upstream" remote if the branch was pushed to
+// a fork. Returns nothing if there is no upstream project.
+func (t *target) lookupUpstream(base string) ([]pullRequestInfo, error) {
+	upstream, err := t.repository.Remote("upstream")
+	if err != nil {
+		// GitLab knows which project the fork was created from
+		if t.host.Type == "gitlab" {
+			return t.lookupGitLabFork(t.host, "", base)
+		}
+
+		return nil, nil
+	}
+
+	host, projectPath, ok, err := t.upstreamProject(upstream)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if host.Type == "gitlab" {
+		return t.lookupGitLabFork(host, projectPath, base)
+	}
+
+	t.info("No pull request found in fork, checking %s\n", color.GreenString("upstream"))
+
+	return lookupPullRequests(host, forkBranch(host, t.projectPath, t.branch), projectPath, base)
+}
+
+// Host and project path of the upstream remote, false if it's the fork itself
+// or on another provider
+func (t *target) upstreamProject(upstream *git.Remote) (remoteHost, string, bool, error) {
+	upstreamURL := preferredURL(t.repository, upstream)
+	if upstreamURL == t.remoteURL {
+		return remoteHost{}, "", false, nil
+	}
+
+	hostName, projectPath, err := parseRemoteURL(upstreamURL)
+	if err != nil {
+		return remoteHost{}, "", false, nil
+	}
+
+	// Same project written differently, e.g. "Org/Repo" and "org/repo.git"
+	if hostName == t.host.Name && strings.EqualFold(projectPath, t.projectPath) {
+		return remoteHost{}, "", false, nil
+	}
+
+	host, ok, warning, err := resolveHostWithWarning(hostName)
+	if err != nil {
+		return remoteHost{}, "", false, err
+	}
+	if !ok || host.Type != t.host.Type {
+		return remoteHost{}, "", false, nil
+	}
+	t.warn(warning)
+
+	return host, projectPath, true, nil
+}
+
+// Find merge requests opened from the fork in targetPath, or in the project
+// the fork was created from if targetPath is empty. Only the fork's own branch
+// is matched, not branches with the same name in other forks.
+func (t *target) lookupGitLabFork(host remoteHost, targetPath string, base string) ([]pullRequestInfo, error) {
+	fork, err := gitlab.Project(host.API, t.projectPath, host.Token)
+	if err != nil {
+		debug.Printf("Unable to get fork project: %s", err)
+		return nil, nil
+	}
+
+	if targetPath == "" {
+		if fork.ForkedFromProject == nil {
+			return nil, nil
+		}
+
+		targetPath = fork.ForkedFromProject.PathWithNamespace
+	}
+
+	t.info("No merge request found in fork, checking %s\n", color.GreenString(targetPath))
+
+	found, err := gitlab.Provider{BaseURL: host.API}.FindForkPullRequests(targetPath, host.Token, t.branch, base, fork.ID)
+	if errors.Is(err, providers.ErrNotFound) {
+		return nil, nil
+	}
+
+	return pullRequestInfos(found), err
+}
@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type StatusOptions struct {
+	// Name of the remote, default remote when empty
+	Remote string
+	// Days without updates after which the PR is stale, "stale_days" from config when 0
+	StaleDays int
+}
+
+// Summary of a pull request shown by the status command
+type pullRequestStatus struct {
+	Number    int
+	Title     string
+	URL       string
+	State     string // open, draft, merged or closed
+	Mergeable string // yes, conflicts, blocked or unknown
+	Review    string // approved, changes requested, review required or none
+	Checks    string // passing, failing, pending or none
+	UpdatedAt time.Time
+}
+
+// Print state, mergeability, review decision and CI status of the current branch's pull request
+func Status(repoPath string, options StatusOptions) {
+	project := resolveProject(repoPath, options.Remote)
+
+	var status pullRequestStatus
+
+	switch project.host.Type {
+	case "github":
+		status = githubStatus(project)
+	case "gitlab":
+		status = gitlabStatus(project)
+	default:
+		color.Red("Status is not supported for %s yet.", project.host.Name)
+		os.Exit(1)
+	}
+
+	printStatus(status)
+
+	conf := config.Current()
+	if options.StaleDays != 0 {
+		conf.StaleDays = options.StaleDays
+	}
+
+	warnIfStale(status, conf.StaleDuration())
+}
+
+// Print a warning if an open pull request wasn't updated for longer than staleAfter,
+// 0 disables the warning
+func warnIfStale(status pullRequestStatus, staleAfter time.Duration) {
+	if staleAfter == 0 || status.UpdatedAt.IsZero() || (status.State != "open" && status.State != "draft") {
+		return
+	}
+
+	if idle := time.Since(status.UpdatedAt); idle > staleAfter {
+		fmt.Println()
+		color.Yellow("No updates for %d days, the pull request may be forgotten.", int(idle.Hours()/24))
+	}
+}
+
+// Time since t in days, or hours for the last day
+func timeAgo(t time.Time) string {
+	elapsed := time.Since(t)
+
+	switch days := int(elapsed.Hours() / 24); {
+	case elapsed < time.Hour:
+		return "just now"
+	case days == 0:
+		return fmt.Sprintf("%d hours ago", int(elapsed.Hours()))
+	case days == 1:
+		return "1 day ago"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
+}
+
+func githubStatus(project project) pullRequestStatus {
+	host := project.host
+
+	found, ok := findPullRequest(host, project.branch, project.path)
+	if !ok {
+		fmt.Println("No open pull request found for current branch")
+		os.Exit(exitNoPullRequest)
+	}
+
+	pullRequest, err := github.PullRequest(host.API, project.path, host.Token, found.Number)
+	handleError(err, "Unable to get pull request")
+
+	review, err := github.ReviewDecision(host.API, project.path, host.Token, pullRequest)
+	handleError(err, "Unable to get reviews")
+
+	checks, err := github.CommitChecks(host.API, project.path, host.Token, pullRequest.Head.SHA)
+	handleError(err, "Unable to get CI status")
+
+	status := pullRequestStatus{
+		Number:    pullRequest.Number,
+		Title:     pullRequest.Title,
+		URL:       pullRequest.HtmlURL,
+		State:     pullRequest.State,
+		UpdatedAt: pullRequest.UpdatedAt,
+	}
+
+	if pullRequest.Merged {
+		status.State = "merged"
+	} else if pullRequest.Draft {
+		status.State = "draft"
+	}
+
+	switch {
+	case pullRequest.Mergeable == nil:
+		status.Mergeable = "unknown"
+	case pullRequest.MergeableState == "dirty":
+		status.Mergeable = "conflicts"
+	case pullRequest.MergeableState == "blocked":
+		status.Mergeable = "blocked"
+	case *pullRequest.Mergeable:
+		status.Mergeable = "yes"
+	default:
+		status.Mergeable = "conflicts"
+	}
+
+	switch review {
+	case github.ReviewApproved:
+		status.Review = "approved"
+	case github.ReviewChangesRequested:
+		status.Review = "changes requested"
+	case github.ReviewRequired:
+		status.Review = "review required"
+	default:
+		status.Review = "none"
+	}
+
+	switch checks {
+	case github.ChecksSuccess:
+		status.Checks = "passing"
+	case github.ChecksFailure:
+		status.Checks = "failing"
+	case github.ChecksPending:
+		status.Checks = "pending"
+	default:
+		status.Checks = "none"
+	}
+
+	return status
+}
+
+func gitlabStatus(project project) pullRequestStatus {
+	host := project.host
+
+	found, ok := findPullRequest(host, project.branch, project.path)
+	if !ok {
+		fmt.Println("No open merge request found for current branch")
+		os.Exit(exitNoPullRequest)
+	}
+
+	mergeRequest, err := gitlab.MergeRequest(host.API, project.path, host.Token, found.Number)
+	handleError(err, "Unable to get merge request")
+
+	approvals, err := gitlab.Approvals(host.API, project.path, host.Token, found.Number)
+	handleError(err, "Unable to get approvals")
+
+	status := pullRequestStatus{
+		Number:    mergeRequest.IID,
+		Title:     mergeRequest.Title,
+		URL:       mergeRequest.WebUrl,
+		State:     mergeRequest.State,
+		UpdatedAt: mergeRequest.UpdatedAt,
+	}
+
+	if mergeRequest.State == "opened" {
+		status.State = "open"
+	}
+	if mergeRequest.IsDraft() {
+		status.State = "draft"
+	}
+
+	switch {
+	case mergeRequest.HasConflicts:
+		status.Mergeable = "conflicts"
+	case mergeRequest.DetailedMergeStatus == "mergeable":
+		status.Mergeable = "yes"
+	case mergeRequest.DetailedMergeStatus == "checking" || mergeRequest.DetailedMergeStatus == "unchecked" || mergeRequest.DetailedMergeStatus == "":
+		status.Mergeable = "unknown"
+	default:
+		status.Mergeable = "blocked"
+	}
+
+	switch {
+	case len(approvals.ApprovedBy) > 0 && approvals.ApprovalsLeft == 0:
+		status.Review = "approved"
+	case approvals.ApprovalsLeft > 0:
+		status.Review = "review required"
+	default:
+		status.Review = "none"
+	}
+
+	status.Checks = "none"
+	if mergeRequest.HeadPipeline != nil {
+		switch mergeRequest.HeadPipeline.Status {
+		case "success":
+			status.Checks = "passing"
+		case "failed", "canceled":
+			status.Checks = "failing"
+		case "skipped":
+			status.Checks = "none"
+		default:
+			status.Checks = "pending"
+		}
+	}
+
+	return status
+}
+
+func printStatus(status pullRequestStatus) {
+	fmt.Println()
+	fmt.Printf("%s %s\n", color.New(color.Bold).Sprintf("#%d", status.Number), status.Title)
+	fmt.Println(color.BlueString(status.URL))
+	fmt.Println()
+	fmt.Printf("State:      %s\n", statusColor(status.State))
+	fmt.Printf("Mergeable:  %s\n", statusColor(status.Mergeable))
+	fmt.Printf("Review:     %s\n", statusColor(status.Review))
+	fmt.Printf("Checks:     %s\n", statusColor(status.Checks))
+
+	if !status.UpdatedAt.IsZero() {
+		fmt.Printf("Updated:    %s\n", timeAgo(status.UpdatedAt))
+	}
+}
+
+// Color status value: green for good, red for bad, yellow for waiting
+func statusColor(value string) string {
+	switch value {
+	case "open", "yes", "approved", "passing", "merged":
+		return color.GreenString(value)
+	case "conflicts", "changes requested", "failing", "closed", "blocked":
+		return color.RedString(value)
+	case "pending", "review required", "draft":
+		return color.YellowString(value)
+	default:
+		return value
+	}
+}
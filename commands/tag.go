@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/wowu/pro/providers"
+	"github.com/wowu/pro/providers/azure"
+
+	"github.com/fatih/color"
+)
+
+// Open release page of the tag HEAD is at, as tags have no pull requests
+func openTag(stdout io.Writer, remoteURL string, tag string, options OpenOptions) {
+	host, projectPath := resolveRemoteURL(remoteURL)
+
+	printInfo("HEAD is at tag %s, opening its release page.\n", color.GreenString(tag))
+
+	showResult(stdout, Result{URL: tagURL(host, projectPath, tag), Provider: host.Type}, options)
+}
+
+// Web URL of the tag, its release page where providers have one
+func tagURL(host remoteHost, projectPath string, tag string) string {
+	home := homeURL(host.Name, projectPath)
+
+	switch host.Type {
+	case "gitlab":
+		// Releases page is missing for tags without a release, tag page always exists
+		return home + "/-/tags/" + providers.EscapePath(tag)
+	case "bitbucket":
+		return home + "/src/" + providers.EscapePath(tag)
+	case "azure":
+		repository, err := azure.ParseRepository(host.Name, projectPath)
+		handleError(err, "Unable to parse Azure DevOps repository path")
+
+		return repository.WebURL() + "?version=GT" + url.QueryEscape(tag)
+	case "sourcehut":
+		return home + "/refs/" + providers.EscapePath(tag)
+	case "gerrit":
+		// Gitiles plugin bundled with Gerrit
+		return fmt.Sprintf("https://%s/plugins/gitiles/%s/+/refs/tags/%s", host.Name, projectPath, providers.EscapePath(tag))
+	default:
+		// GitHub and Gitea show the tag on this page even without a release
+		return home + "/releases/tag/" + providers.EscapePath(tag)
+	}
+}
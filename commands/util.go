@@ -1,8 +1,15 @@
 package commands
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
 )
 
 // Print error and exit if error is present
@@ -17,3 +24,70 @@ func handleError(err error, reason string) {
 		os.Exit(1)
 	}
 }
+
+// Print prompt and read a line from stdin
+func readLine(prompt string) string {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	handleError(err, "Error while reading input")
+
+	return strings.TrimSpace(line)
+}
+
+// Suppress informational messages, set by SetQuiet
+var quiet bool
+
+// Original stdout, set when messages are redirected to stderr
+var resultOutput io.Writer
+
+// Print only results, like the URL printed with --print, to stdout. Errors and
+// warnings go to stderr, informational messages are not printed.
+func SetQuiet() {
+	quiet = true
+	redirectMessagesToStderr()
+}
+
+// Print informational message, unless --quiet is set
+func printInfo(format string, a ...interface{}) {
+	if !quiet {
+		fmt.Printf(format, a...)
+	}
+}
+
+// Writer for results, stdout even if messages are redirected to stderr
+func results() io.Writer {
+	if resultOutput != nil {
+		return resultOutput
+	}
+
+	return os.Stdout
+}
+
+// Send all status messages to stderr to keep stdout clean for machine-readable output.
+// Returns the original stdout.
+func redirectMessagesToStderr() io.Writer {
+	if resultOutput != nil {
+		return resultOutput
+	}
+
+	stdout := os.Stdout
+	resultOutput = stdout
+	os.Stdout = os.Stderr
+	color.Output = color.Error
+
+	// Colors are enabled based on stdout, messages are now printed to stderr
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		color.NoColor = true
+	}
+
+	return stdout
+}
+
+// Print value as indented JSON
+func printJSON(w io.Writer, value interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(value)
+	handleError(err, "Unable to encode JSON")
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wowu/pro/providers/azure"
+	"github.com/wowu/pro/providers/bitbucket"
+	"github.com/wowu/pro/providers/gitea"
+	"github.com/wowu/pro/providers/github"
+	"github.com/wowu/pro/providers/gitlab"
+
+	"github.com/fatih/color"
+)
+
+type WhoamiOptions struct {
+	// Self-hosted instance. Empty host means public instance, e.g. gitlab.com or github.com.
+	Host string
+	// Name of the remote used when no provider is given, default remote when empty
+	Remote string
+}
+
+// Print user authenticated with the stored token and the token scopes.
+// Without provider, the host of the current repository's remote is used.
+func Whoami(provider string, options WhoamiOptions) {
+	hostName := options.Host
+	if hostName == "" {
+		hostName = whoamiHostName(provider, options.Remote)
+	}
+
+	host, ok := resolveHost(hostName)
+	if !ok && provider == "" {
+		exitUnknownRemote()
+	} else if !ok {
+		color.Red("%s is not configured. Run `pro auth %s --host %s` to add it.", hostName, provider, hostName)
+		os.Exit(exitUnknownHost)
+	}
+
+	if provider != "" && host.Type != provider {
+		color.Red("%s is a %s host, not %s.", hostName, host.Type, provider)
+		os.Exit(1)
+	}
+
+	if host.Type == "sourcehut" {
+		fmt.Println("SourceHut doesn't use a token.")
+		return
+	}
+
+	if host.Token == "" {
+		color.Red("Token for %s is not set. Run `%s` to set it.", host.Name, host.authCommand())
+		os.Exit(exitAuthError)
+	}
+
+	user, scopes, err := authenticatedUser(host)
+	handleProviderError(host, err, "Unable to get user")
+
+	fmt.Printf("Host:   %s\n", host.Name)
+	fmt.Printf("User:   %s\n", color.GreenString(user))
+
+	switch {
+	case scopes == nil:
+		fmt.Printf("Scopes: %s\n", color.YellowString("not reported by %s", host.Type))
+	case len(scopes) == 0:
+		fmt.Printf("Scopes: %s\n", color.YellowString("none"))
+	default:
+		fmt.Printf("Scopes: %s\n", strings.Join(scopes, ", "))
+	}
+}
+
+// Public host of the provider, or the remote host of the current repository
+func whoamiHostName(provider string, remoteName string) string {
+	switch provider {
+	case "github":
+		return "github.com"
+	case "gitlab":
+		return "gitlab.com"
+	case "bitbucket":
+		return "bitbucket.org"
+	case "azure":
+		return "dev.azure.com"
+	case "gitea":
+		fmt.Println("Gitea is always self-hosted, please specify host, e.g. `pro whoami --host gitea.example.com gitea`")
+		os.Exit(1)
+	}
+
+	repository := openRepo(".")
+
+	if remoteName == "" {
+		remoteName = defaultRemote()
+	}
+
+	hostName, _, err := parseRemoteURL(preferredURL(repository, getRemote(repository, remoteName)))
+	handleError(err, "Unable to parse remote URL")
+
+	return hostName
+}
+
+// User name and token scopes. Scopes are nil if the provider doesn't report
+// them, and empty if the token has none.
+func authenticatedUser(host remoteHost) (string, []string, error) {
+	switch host.Type {
+	case "github":
+		if host.usesAppToken() {
+			return "GitHub App installation", nil, github.CheckInstallationToken(host.API, host.Token)
+		}
+
+		user, err := github.User(host.API, host.Token)
+		return user.Login, user.Scopes, err
+	case "gitlab":
+		user, err := gitlab.User(host.API, host.Token)
+		if err != nil {
+			return "", nil, err
+		}
+
+		token, err := gitlab.Token(host.API, host.Token)
+		if errors.Is(err, gitlab.ErrNotFound) {
+			// Older GitLab versions don't have the endpoint
+			return user.Username, nil, nil
+		}
+
+		return user.Username, append([]string{}, token.Scopes...), err
+	case "bitbucket":
+		user, err := bitbucket.User(host.Token)
+		return user.Username, nil, err
+	case "gitea":
+		user, err := gitea.User(host.API, host.Token)
+		return user.Login, nil, err
+	case "azure":
+		profile, err := azure.User(host.Token)
+		return profile.DisplayName, nil, err
+	default:
+		return "", nil, errors.New("unknown remote type")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Completion scripts call pro with --generate-bash-completion flag, which
+// prints possible commands, flags or arguments for the current command line.
+// Based on scripts from urfave/cli autocomplete directory.
+var completionScripts = map[string]string{
+	"bash": `_pro_bash_autocomplete() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} ${cur} --generate-bash-completion )
+  else
+    opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} --generate-bash-completion )
+  fi
+  COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+  return 0
+}
+
+complete -o bashdefault -o default -o nospace -F _pro_bash_autocomplete pro
+`,
+	"zsh": `#compdef pro
+
+_pro_zsh_autocomplete() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _pro_zsh_autocomplete pro
+`,
+	"fish": `function __pro_complete
+  set -l args (commandline -opc)
+  set -l cur (commandline -ct)
+  if string match -q -- '-*' $cur
+    $args $cur --generate-bash-completion 2>/dev/null
+  else
+    $args --generate-bash-completion 2>/dev/null
+  end
+end
+
+complete -c pro -f -a '(__pro_complete)'
+`,
+}
+
+var completionCommand = &cli.Command{
+	Name:      "completion",
+	ArgsUsage: "[bash|zsh|fish]",
+	Usage:     "Print shell completion script",
+	UsageText: "source <(pro completion bash)\npro completion fish > ~/.config/fish/completions/pro.fish",
+	BashComplete: func(c *cli.Context) {
+		if c.NArg() > 0 {
+			return
+		}
+
+		for _, shell := range []string{"bash", "zsh", "fish"} {
+			fmt.Println(shell)
+		}
+	},
+	Action: func(c *cli.Context) error {
+		script, ok := completionScripts[c.Args().Get(0)]
+		if c.NArg() != 1 || !ok {
+			fmt.Println("Please specify shell (bash, zsh or fish)")
+			os.Exit(1)
+		}
+
+		fmt.Print(script)
+
+		return nil
+	},
+}
+
+// Complete provider argument of auth and logout commands
+func completeProviders(c *cli.Context) {
+	if c.NArg() > 0 {
+		return
+	}
+
+	for _, provider := range providerNames {
+		fmt.Println(provider)
+	}
+}
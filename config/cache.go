@@ -0,0 +1,66 @@
+package config
+
+import "time"
+
+// Default time for which pull request lookups are cached
+const DefaultCacheTTL = 60 * time.Second
+
+// Cached pull request lookup
+type CacheEntry struct {
+	URL     string    `json:"url"`
+	Number  int       `json:"number"`
+	Title   string    `json:"title,omitempty"`
+	Draft   bool      `json:"draft,omitempty"`
+	HeadSHA string    `json:"head_sha"`
+	Time    time.Time `json:"time"`
+}
+
+// Time for which lookups are cached. CacheTTL is in seconds, negative value
+// disables caching.
+func (c Config) CacheDuration() time.Duration {
+	if c.CacheTTL < 0 {
+		return 0
+	}
+
+	if c.CacheTTL == 0 {
+		return DefaultCacheTTL
+	}
+
+	return time.Duration(c.CacheTTL) * time.Second
+}
+
+// Get cached entry for given key. Returns false if there is no entry,
+// it's older than ttl or it was stored for a different HEAD commit.
+func GetCache(key string, headSHA string, ttl time.Duration) (CacheEntry, bool) {
+	entry, ok := readCache()[key]
+	if !ok || entry.HeadSHA != headSHA || time.Since(entry.Time) > ttl {
+		return CacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Store entry under given key, dropping expired entries. Errors are ignored,
+// as cache is only an optimization.
+func SetCache(key string, entry CacheEntry, ttl time.Duration) {
+	entries := readCache()
+	for k, e := range entries {
+		if time.Since(e.Time) > ttl {
+			delete(entries, k)
+		}
+	}
+
+	entry.Time = time.Now()
+	entries[key] = entry
+
+	cacheStore.write(entries)
+}
+
+func readCache() map[string]CacheEntry {
+	entries := map[string]CacheEntry{}
+	if !cacheStore.read(&entries) {
+		return map[string]CacheEntry{}
+	}
+
+	return entries
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Token stored by the GitHub CLI (gh) for given host, empty if gh isn't
+// logged in or keeps the token in the system keyring
+func GitHubCLIToken(host string) string {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		configDir, err := configdir()
+		if err != nil {
+			return ""
+		}
+
+		dir = filepath.Join(configDir, "gh")
+	}
+
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if !readCLIConfig(filepath.Join(dir, "hosts.yml"), &hosts) {
+		return ""
+	}
+
+	return hosts[host].OAuthToken
+}
+
+// Token stored by the GitLab CLI (glab) for given host, empty if glab isn't
+// logged in or keeps the token in the system keyring
+func GitLabCLIToken(host string) string {
+	dir := os.Getenv("GLAB_CONFIG_DIR")
+	if dir == "" {
+		configDir, err := configdir()
+		if err != nil {
+			return ""
+		}
+
+		dir = filepath.Join(configDir, "glab-cli")
+	}
+
+	var glabConfig struct {
+		Hosts map[string]struct {
+			Token string `yaml:"token"`
+		} `yaml:"hosts"`
+	}
+	if !readCLIConfig(filepath.Join(dir, "config.yml"), &glabConfig) {
+		return ""
+	}
+
+	return glabConfig.Hosts[host].Token
+}
+
+// Config files of other tools are optional, so errors only mean there's no token
+func readCLIConfig(path string, out interface{}) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return yaml.Unmarshal(data, out) == nil
+}
@@ -5,77 +5,392 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	GitHubToken string `yaml:"github_token"`
-	GitLabToken string `yaml:"gitlab_token"`
+	GitHubToken    string   `yaml:"github_token"`
+	GitLabToken    string   `yaml:"gitlab_token"`
+	BitbucketToken string   `yaml:"bitbucket_token,omitempty"`
+	AzureToken     string   `yaml:"azure_token,omitempty"`
+	Hosts          []Host   `yaml:"hosts,omitempty"`
+	MainBranches   []string `yaml:"main_branches,omitempty"`
+	Browser        string   `yaml:"browser,omitempty"`
+	CacheTTL       int      `yaml:"cache_ttl,omitempty"`
+	StaleDays      int      `yaml:"stale_days,omitempty"`
+	Remote         string   `yaml:"remote,omitempty"`
+	MaxAttempts    int      `yaml:"max_attempts,omitempty"`
+	Timeout        int      `yaml:"timeout,omitempty"`
+	Proxy          string   `yaml:"proxy,omitempty"`
+	CACert         string   `yaml:"ca_cert,omitempty"`
+	// What to do with the URL when no output flag is given, "open" or "print"
+	DefaultAction string `yaml:"default_action,omitempty"`
+	// Regular expressions finding issue numbers in branch names, the first group is the number
+	IssuePatterns []string `yaml:"issue_patterns,omitempty"`
+	// User-Agent of API requests, "pro/<version>" by default
+	UserAgent string `yaml:"user_agent,omitempty"`
+	// Headers added to all API requests, e.g. required by a gateway
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Token sets selected with --profile
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
 }
 
-// Read config file and return config object
+// Config file set by the --config flag. PRO_CONFIG environment variable
+// and ~/.config/pro/config.yml are used when empty.
+var File string
+
+// Branches that open repository home page instead of a pull request
+var DefaultMainBranches = []string{"master", "main", "trunk", "develop"}
+
+// Issue numbers in branch names like "issue-123", "fix/GH-456" or "123-login"
+var DefaultIssuePatterns = []string{`(?i)(?:^|/)(?:issue|gh|bug|fix)?[-_#]?(\d+)(?:$|[-_/])`}
+
+// Default number of days without updates after which a pull request is stale
+const DefaultStaleDays = 14
+
+// Time without updates after which status warns about a stale pull request.
+// StaleDays is in days, negative value disables the warning.
+func (c Config) StaleDuration() time.Duration {
+	if c.StaleDays < 0 {
+		return 0
+	}
+
+	days := c.StaleDays
+	if days == 0 {
+		days = DefaultStaleDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Check if branch is one of the configured main branches
+func (c Config) IsMainBranch(branch string) bool {
+	mainBranches := c.MainBranches
+	if len(mainBranches) == 0 {
+		mainBranches = DefaultMainBranches
+	}
+
+	for _, mainBranch := range mainBranches {
+		if mainBranch == branch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Self-hosted GitHub or GitLab instance
+type Host struct {
+	Host  string `yaml:"host"`
+	Type  string `yaml:"type"`
+	API   string `yaml:"api,omitempty"`
+	Token string `yaml:"token,omitempty"`
+	// URL templates with {host}, {path}, {branch} and {number} placeholders,
+	// used instead of URLs built for the provider type
+	HomeURL    string `yaml:"home_url,omitempty"`
+	CompareURL string `yaml:"compare_url,omitempty"`
+	PRURL      string `yaml:"pr_url,omitempty"`
+}
+
+// Find host configuration by host name
+func (c Config) FindHost(host string) (Host, bool) {
+	for _, h := range c.Hosts {
+		if h.Host == host {
+			return h, true
+		}
+	}
+
+	return Host{}, false
+}
+
+// Store token for given host, registering the host if it's not configured yet
+func (c *Config) SetHostToken(host string, hostType string, token string) {
+	for i := range c.Hosts {
+		if c.Hosts[i].Host == host {
+			c.Hosts[i].Type = hostType
+			c.Hosts[i].Token = token
+			return
+		}
+	}
+
+	c.Hosts = append(c.Hosts, Host{Host: host, Type: hostType, Token: token})
+}
+
+// Read config file and return config object with tokens of the selected
+// profile. The command loads the config with Load before anything else and
+// exits on errors, so defaults are only returned if the file breaks later.
 func Get() Config {
-	// check if file exists
-	_, err := os.Stat(configfile())
+	config, _ := Load()
+	return config
+}
+
+// Same as Get, returning an error if the config file can't be read or parsed
+func Load() (Config, error) {
+	config, err := read()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return Config{}
-		} else {
-			fmt.Println("Unable to stat config file:", err)
-			os.Exit(1)
-		}
+		return Config{}, err
+	}
+
+	if name := activeProfile(); name != "" {
+		return config.withProfile(name), nil
 	}
 
-	data, err := ioutil.ReadFile(configfile())
+	return config, nil
+}
+
+// Check that the config file can be read and parsed. A missing file is fine,
+// as defaults are used then.
+func Check() error {
+	path, err := configfile()
 	if err != nil {
-		fmt.Println("Unable to read config file:", err)
-		os.Exit(1)
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
 	var config Config
+	return yaml.Unmarshal(data, &config)
+}
+
+// Contents of the config file read in this process, as settings are read many
+// times in a single run. Modification time and size tell if the file changed
+// since, for long-running programs using the library.
+var fileCache struct {
+	path    string
+	version string
+	data    []byte
+}
+
+// Read config file unless it's unchanged since the last read, nil data means
+// the file doesn't exist
+func readFile(path string) ([]byte, error) {
+	version := fileVersion(path)
+	if fileCache.path == path && fileCache.version == version {
+		return fileCache.data, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fileCache.path, fileCache.version, fileCache.data = path, version, data
+
+	return data, nil
+}
+
+// Modification time and size of a file, empty if it doesn't exist
+func fileVersion(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
+
+// Read config file as it is, with top-level tokens
+func read() (Config, error) {
+	path, err := configfile()
+	if err != nil {
+		return Config{}, err
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	if data == nil {
+		return config, nil
+	}
+
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
-		fmt.Println("Unable to unmarshal config file:", err)
-		os.Exit(1)
+		return Config{}, fmt.Errorf("%s: %w", path, err)
 	}
 
-	return config
+	return config, nil
 }
 
-func Save(config Config) {
+// Write config file. Tokens go to the selected profile. A file which can't be
+// parsed is not overwritten, as settings in it would be lost.
+func Save(config Config) error {
+	stored, err := read()
+	if err != nil {
+		return err
+	}
+
+	if name := activeProfile(); name != "" {
+		config = config.withTokensInProfile(name, stored)
+	}
+
+	path, err := configfile()
+	if err != nil {
+		return err
+	}
+
 	// Make sure the config directory exists
-	configdir, _ := filepath.Split(configfile())
-	err := os.MkdirAll(configdir, 0750)
+	err = os.MkdirAll(filepath.Dir(path), 0750)
 	if err != nil {
-		fmt.Println("Unable to create config directory:", err)
-		os.Exit(1)
+		return err
 	}
 
 	data, err := yaml.Marshal(config)
 	if err != nil {
-		fmt.Println("Unable to marshal config:", err)
-		os.Exit(1)
+		return err
 	}
 
-	err = ioutil.WriteFile(configfile(), data, 0600)
+	err = ioutil.WriteFile(path, data, 0600)
 	if err != nil {
-		fmt.Println("Unable to write config file:", err)
-		os.Exit(1)
+		return err
 	}
+
+	fileCache.path, fileCache.version, fileCache.data = path, fileVersion(path), data
+
+	return nil
 }
 
-func configdir() string {
+func configdir() (string, error) {
 	home, err := homedir.Dir()
 	if err != nil {
-		fmt.Println("Unable to get home directory:", err)
-		os.Exit(1)
+		return "", fmt.Errorf("unable to get home directory: %w", err)
 	}
 
-	return filepath.Join(home, ".config")
+	return filepath.Join(home, ".config"), nil
 }
 
-func configfile() string {
-	return filepath.Join(configdir(), "pro", "config.yml")
+func configfile() (string, error) {
+	path := File
+	if path == "" {
+		path = os.Getenv("PRO_CONFIG")
+	}
+
+	if path == "" {
+		dir, err := configdir()
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(dir, "pro", "config.yml"), nil
+	}
+
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to expand config file path: %w", err)
+	}
+
+	return path, nil
+}
+
+// Location of the config file
+func Path() (string, error) {
+	return configfile()
+}
+
+// Names of config keys which can be read and changed with GetKey and SetKey
+func Keys() []string {
+	var keys []string
+
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		if isSimpleKind(configType.Field(i).Type) {
+			keys = append(keys, yamlName(configType.Field(i)))
+		}
+	}
+
+	return keys
+}
+
+// Get value of a config key. Lists are joined with commas.
+func (c Config) GetKey(key string) (string, error) {
+	value, err := keyValue(reflect.ValueOf(&c).Elem(), key)
+	if err != nil {
+		return "", err
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		return strings.Join(value.Interface().([]string), ","), nil
+	case reflect.Int:
+		return strconv.Itoa(int(value.Int())), nil
+	default:
+		return value.String(), nil
+	}
+}
+
+// Set value of a config key. Lists are given as comma-separated values,
+// empty value resets the key to its default.
+func (c *Config) SetKey(key string, value string) error {
+	field, err := keyValue(reflect.ValueOf(c).Elem(), key)
+	if err != nil {
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Int:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		field.SetInt(int64(number))
+	default:
+		field.SetString(value)
+	}
+
+	return nil
+}
+
+// Find struct field by its yaml name
+func keyValue(config reflect.Value, key string) (reflect.Value, error) {
+	for i := 0; i < config.NumField(); i++ {
+		field := config.Type().Field(i)
+		if yamlName(field) == key && isSimpleKind(field.Type) {
+			return config.Field(i), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("unknown key \"%s\", available keys: %s", key, strings.Join(Keys(), ", "))
+}
+
+func yamlName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
+
+// Strings, numbers and lists of strings can be edited, hosts have to be
+// changed in the file or with the auth command
+func isSimpleKind(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Int:
+		return true
+	case reflect.Slice:
+		return fieldType.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
 }
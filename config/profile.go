@@ -0,0 +1,85 @@
+package config
+
+import "os"
+
+// Set of tokens used instead of the top-level ones, e.g. for work and personal accounts
+type Profile struct {
+	GitHubToken    string `yaml:"github_token,omitempty"`
+	GitLabToken    string `yaml:"gitlab_token,omitempty"`
+	BitbucketToken string `yaml:"bitbucket_token,omitempty"`
+	AzureToken     string `yaml:"azure_token,omitempty"`
+	// Tokens of self-hosted instances by host name
+	HostTokens map[string]string `yaml:"host_tokens,omitempty"`
+}
+
+// Profile set by the --profile flag. PRO_PROFILE environment variable and
+// "profile" from .pro.yml are used when empty.
+var ProfileName string
+
+// Name of the selected profile, empty if top-level tokens are used
+func activeProfile() string {
+	if ProfileName != "" {
+		return ProfileName
+	}
+
+	if name := os.Getenv("PRO_PROFILE"); name != "" {
+		return name
+	}
+
+	return repoConfig.Profile
+}
+
+// Replace tokens with the ones from given profile. A profile that doesn't
+// exist yet has no tokens, it's created when a token is saved.
+func (c Config) withProfile(name string) Config {
+	profile := c.Profiles[name]
+
+	c.GitHubToken = profile.GitHubToken
+	c.GitLabToken = profile.GitLabToken
+	c.BitbucketToken = profile.BitbucketToken
+	c.AzureToken = profile.AzureToken
+
+	c.Hosts = append([]Host{}, c.Hosts...)
+	for i := range c.Hosts {
+		c.Hosts[i].Token = profile.HostTokens[c.Hosts[i].Host]
+	}
+
+	return c
+}
+
+// Move tokens of config returned by Get back to the selected profile, keeping
+// top-level tokens as they are in the file
+func (c Config) withTokensInProfile(name string, file Config) Config {
+	profile := Profile{
+		GitHubToken:    c.GitHubToken,
+		GitLabToken:    c.GitLabToken,
+		BitbucketToken: c.BitbucketToken,
+		AzureToken:     c.AzureToken,
+	}
+
+	c.GitHubToken = file.GitHubToken
+	c.GitLabToken = file.GitLabToken
+	c.BitbucketToken = file.BitbucketToken
+	c.AzureToken = file.AzureToken
+
+	c.Hosts = append([]Host{}, c.Hosts...)
+	for i := range c.Hosts {
+		if c.Hosts[i].Token != "" {
+			if profile.HostTokens == nil {
+				profile.HostTokens = map[string]string{}
+			}
+			profile.HostTokens[c.Hosts[i].Host] = c.Hosts[i].Token
+		}
+
+		fileHost, _ := file.FindHost(c.Hosts[i].Host)
+		c.Hosts[i].Token = fileHost.Token
+	}
+
+	c.Profiles = map[string]Profile{}
+	for profileName, fileProfile := range file.Profiles {
+		c.Profiles[profileName] = fileProfile
+	}
+	c.Profiles[name] = profile
+
+	return c
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+)
+
+// Remote of a repository resolved to web host and project path
+type ProjectEntry struct {
+	// Root of the repository working tree, entries of removed repositories are dropped
+	Root      string `json:"root"`
+	RemoteURL string `json:"remote_url"`
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	// Modification times of SSH config files, as host aliases are resolved with them
+	SSHConfig string `json:"ssh_config"`
+	// Version of remote URL parsing the entry was resolved with
+	Version int `json:"version"`
+}
+
+// Get resolved project stored for given key. Callers check that it was
+// resolved from the same remote URL and SSH config.
+func GetProject(key string) (ProjectEntry, bool) {
+	entry, ok := readProjects()[key]
+	return entry, ok
+}
+
+// Store resolved project under given key, dropping entries of repositories
+// which don't exist anymore. Errors are ignored, as the cache is only an
+// optimization.
+func SetProject(key string, entry ProjectEntry) {
+	entries := readProjects()
+	for k, e := range entries {
+		if _, err := os.Stat(e.Root); e.Root == "" || os.IsNotExist(err) {
+			delete(entries, k)
+		}
+	}
+
+	entries[key] = entry
+
+	projectsStore.write(entries)
+}
+
+func readProjects() map[string]ProjectEntry {
+	entries := map[string]ProjectEntry{}
+	if !projectsStore.read(&entries) {
+		return map[string]ProjectEntry{}
+	}
+
+	return entries
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the per-repository config file in the repository root
+const RepoConfigFile = ".pro.yml"
+
+// Per-repository settings, merged over the global config
+type RepoConfig struct {
+	Remote       string   `yaml:"remote,omitempty"`
+	MainBranches []string `yaml:"main_branches,omitempty"`
+	// Branch naming convention of the team, see Config.IssuePatterns
+	IssuePatterns []string `yaml:"issue_patterns,omitempty"`
+	// Provider type of the remote host, e.g. "gitlab" for a self-hosted instance
+	Provider string `yaml:"provider,omitempty"`
+	// API base URL of the remote host
+	API string `yaml:"api,omitempty"`
+	// Profile with tokens to use for this repository
+	Profile string `yaml:"profile,omitempty"`
+}
+
+var repoConfig RepoConfig
+
+// Read .pro.yml from the repository root, if it exists. Settings of a
+// previously loaded repository are dropped.
+func LoadRepo(root string) error {
+	repoConfig = RepoConfig{}
+	path := filepath.Join(root, RepoConfigFile)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var loaded RepoConfig
+	err = yaml.Unmarshal(data, &loaded)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	repoConfig = loaded
+
+	return nil
+}
+
+// Per-repository settings loaded by LoadRepo
+func Repo() RepoConfig {
+	return repoConfig
+}
+
+// Global config with per-repository settings applied. Use it for reading
+// settings only, as Save would write repository settings to the global file.
+func Current() Config {
+	config := Get()
+
+	if repoConfig.Remote != "" {
+		config.Remote = repoConfig.Remote
+	}
+
+	if len(repoConfig.MainBranches) > 0 {
+		config.MainBranches = repoConfig.MainBranches
+	}
+
+	if len(repoConfig.IssuePatterns) > 0 {
+		config.IssuePatterns = repoConfig.IssuePatterns
+	}
+
+	return config
+}
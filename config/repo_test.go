@@ -0,0 +1,29 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRepoDropsPreviousRepository(t *testing.T) {
+	withConfig := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(withConfig, RepoConfigFile), []byte("main_branches: [feature/x]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadRepo(withConfig); err != nil {
+		t.Fatal(err)
+	}
+	if len(Repo().MainBranches) != 1 {
+		t.Fatalf("got main branches %v, want [feature/x]", Repo().MainBranches)
+	}
+
+	if err := LoadRepo(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if len(Repo().MainBranches) != 0 {
+		t.Errorf("got main branches %v of the previous repository", Repo().MainBranches)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// JSON file in the pro config directory, persisting lookups between runs.
+// Errors are ignored, as stored data is only an optimization.
+type jsonStore string
+
+const (
+	cacheStore    jsonStore = "cache.json"
+	projectsStore jsonStore = "projects.json"
+)
+
+func (s jsonStore) path() (string, error) {
+	dir, err := configdir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "pro", string(s)), nil
+}
+
+// Decode stored data into v. Returns false if the file doesn't exist or can't
+// be decoded. Corrupted file is treated as empty and overwritten on next write.
+func (s jsonStore) read(v interface{}) bool {
+	path, err := s.path()
+	if err != nil {
+		return false
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+func (s jsonStore) write(v interface{}) {
+	path, err := s.path()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return
+	}
+
+	_ = ioutil.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,18 @@
+package debug
+
+import (
+	"fmt"
+	"os"
+)
+
+// Set by the --verbose flag
+var Enabled bool
+
+// Print debug message to stderr if verbose output is enabled
+func Printf(format string, args ...interface{}) {
+	if !Enabled {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "[debug] "+format+"\n", args...)
+}
@@ -3,67 +3,1049 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/wowu/pro/commands"
+	"github.com/wowu/pro/config"
+	"github.com/wowu/pro/debug"
+	"github.com/wowu/pro/providers"
 
+	"github.com/fatih/color"
+	"github.com/mitchellh/go-homedir"
 	"github.com/urfave/cli/v2"
 )
 
-var openCommandFlags = []cli.Flag{
+var printFlag = &cli.BoolFlag{
+	Name:    "print",
+	Aliases: []string{"p", "no-open"},
+	Usage:   "print URL instead of opening in browser",
+}
+
+var openFlag = &cli.BoolFlag{
+	Name:  "open",
+	Usage: "open URL in browser even if \"default_action\" in config is print",
+}
+
+var copyFlag = &cli.BoolFlag{
+	Name:    "copy",
+	Aliases: []string{"c"},
+	Usage:   "copy URL to clipboard instead of opening in browser",
+}
+
+var browserFlag = &cli.StringFlag{
+	Name:  "browser",
+	Usage: "browser command to open URL with, \"{url}\" is replaced with the URL",
+}
+
+var privateFlag = &cli.BoolFlag{
+	Name:    "private",
+	Aliases: []string{"incognito"},
+	Usage:   "open URL in a private window of Chrome, Chromium, Brave, Edge or Firefox",
+}
+
+var configFileFlag = &cli.StringFlag{
+	Name:        "config",
+	Usage:       "config file to use",
+	DefaultText: "PRO_CONFIG or ~/.config/pro/config.yml",
+}
+
+// Flags accepted by all commands, before or after the command name
+var commonFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:    "verbose",
+		Aliases: []string{"v"},
+		Usage:   "print debug information, like API requests, to stderr",
+	},
+	configFileFlag,
+	&cli.StringFlag{
+		Name:        "profile",
+		Usage:       "use tokens from given profile in the config file",
+		DefaultText: "PRO_PROFILE or \"profile\" from .pro.yml",
+	},
+	&cli.BoolFlag{
+		Name:    "quiet",
+		Aliases: []string{"q"},
+		Usage:   "print only results like the URL from --print, errors go to stderr",
+	},
+	&cli.BoolFlag{
+		Name:  "no-retry",
+		Usage: "don't retry API requests failing with network or server errors",
+	},
+	&cli.BoolFlag{
+		Name:  "no-color",
+		Usage: "disable colors, also disabled when NO_COLOR is set or output is not a terminal",
+	},
+	&cli.DurationFlag{
+		Name:        "timeout",
+		Usage:       "time limit for a single API request, e.g. 30s",
+		DefaultText: "10s or \"timeout\" seconds from config",
+	},
+	&cli.StringFlag{
+		Name:        "proxy",
+		Usage:       "proxy for API requests, e.g. http://proxy.acme.com:8080",
+		DefaultText: "\"proxy\" from config or HTTPS_PROXY",
+	},
+	&cli.StringFlag{
+		Name:        "ca-cert",
+		Usage:       "PEM file with CA certificates to trust, e.g. of a self-hosted instance",
+		DefaultText: "\"ca_cert\" from config",
+	},
+	&cli.BoolFlag{
+		Name:  "insecure",
+		Usage: "skip TLS certificate verification, only for testing with self-signed certificates",
+	},
+	&cli.StringFlag{
+		Name:        "github-app-token",
+		Usage:       "GitHub App installation token used instead of stored GitHub tokens",
+		DefaultText: "PRO_GITHUB_APP_TOKEN",
+	},
+	&cli.StringFlag{
+		Name:        "app-id",
+		Usage:       "ID of a GitHub App to create an installation token for, with --app-key and --app-installation-id",
+		DefaultText: "PRO_GITHUB_APP_ID",
+	},
+	&cli.StringFlag{
+		Name:        "app-key",
+		Usage:       "private key file of the GitHub App",
+		DefaultText: "PRO_GITHUB_APP_KEY",
+	},
+	&cli.StringFlag{
+		Name:        "app-installation-id",
+		Usage:       "installation ID of the GitHub App",
+		DefaultText: "PRO_GITHUB_APP_INSTALLATION_ID",
+	},
+}
+
+func withCommonFlags(flags ...cli.Flag) []cli.Flag {
+	return append(flags, commonFlags...)
+}
+
+// Whether the doctor command is run, c is either the app or the command context
+func isDoctor(c *cli.Context) bool {
+	return c.Args().First() == "doctor" || (c.Command != nil && c.Command.Name == "doctor")
+}
+
+// Apply common flags and related config. Flags given before the command name
+// are stored in the parent context, so the whole lineage is checked.
+func applyCommonFlags(c *cli.Context) error {
+	for _, ctx := range c.Lineage() {
+		if ctx.IsSet("config") {
+			config.File = ctx.String("config")
+		}
+		if ctx.IsSet("profile") {
+			config.ProfileName = ctx.String("profile")
+		}
+	}
+
+	// Doctor reports a broken config file instead of exiting on it
+	if isDoctor(c) && config.Check() != nil {
+		return nil
+	}
+
+	// Loaded first, so a broken config file is reported once here
+	conf, err := config.Load()
+	if err != nil {
+		fmt.Println("Unable to load config file:", err)
+		os.Exit(1)
+	}
+
+	providers.UserAgent = "pro/" + strings.TrimPrefix(c.App.Version, "v")
+	if conf.UserAgent != "" {
+		providers.UserAgent = conf.UserAgent
+	}
+	providers.Headers = conf.Headers
+	if conf.MaxAttempts > 0 {
+		providers.MaxAttempts = conf.MaxAttempts
+	}
+	if conf.Timeout > 0 {
+		providers.Timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	proxy := conf.Proxy
+	caCert := conf.CACert
+	app := commands.GitHubApp{
+		Token:          os.Getenv("PRO_GITHUB_APP_TOKEN"),
+		ID:             os.Getenv("PRO_GITHUB_APP_ID"),
+		Key:            os.Getenv("PRO_GITHUB_APP_KEY"),
+		InstallationID: os.Getenv("PRO_GITHUB_APP_INSTALLATION_ID"),
+	}
+
+	for _, ctx := range c.Lineage() {
+		if ctx.Bool("verbose") {
+			debug.Enabled = true
+		}
+		if ctx.Bool("no-retry") {
+			providers.NoRetry = true
+		}
+		if ctx.Bool("no-color") {
+			color.NoColor = true
+		}
+		if ctx.Bool("quiet") {
+			commands.SetQuiet()
+		}
+		if ctx.IsSet("timeout") {
+			providers.Timeout = ctx.Duration("timeout")
+		}
+		if ctx.IsSet("proxy") {
+			proxy = ctx.String("proxy")
+		}
+		if ctx.IsSet("ca-cert") {
+			caCert = ctx.String("ca-cert")
+		}
+		if ctx.Bool("insecure") {
+			providers.Insecure = true
+		}
+		if ctx.IsSet("github-app-token") {
+			app.Token = ctx.String("github-app-token")
+		}
+		if ctx.IsSet("app-id") {
+			app.ID = ctx.String("app-id")
+		}
+		if ctx.IsSet("app-key") {
+			app.Key = ctx.String("app-key")
+		}
+		if ctx.IsSet("app-installation-id") {
+			app.InstallationID = ctx.String("app-installation-id")
+		}
+	}
+
+	if app.Key != "" {
+		if key, err := homedir.Expand(app.Key); err == nil {
+			app.Key = key
+		}
+	}
+
+	commands.SetGitHubApp(app)
+
+	if proxy != "" {
+		proxyURL, err := providers.ParseProxy(proxy)
+		if err != nil {
+			color.Red("Invalid proxy: %s", err)
+			os.Exit(1)
+		}
+
+		providers.Proxy = proxyURL
+	}
+
+	if caCert != "" {
+		caCert, err := homedir.Expand(caCert)
+		if err == nil {
+			err = providers.LoadCACert(caCert)
+		}
+		if err != nil {
+			color.Red("Unable to load CA certificate: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if providers.Insecure && !insecureWarned {
+		fmt.Fprintln(os.Stderr, color.RedString("WARNING: TLS certificate verification is disabled. Tokens can be intercepted, don't use --insecure outside of testing."))
+		insecureWarned = true
+	}
+
+	return nil
+}
+
+// applyCommonFlags runs for the app and the subcommand, the warning is printed once
+var insecureWarned bool
+
+var remoteFlag = &cli.StringFlag{
+	Name:        "remote",
+	Aliases:     []string{"r"},
+	Usage:       "name of the git remote to use",
+	DefaultText: "origin or \"remote\" from config",
+}
+
+// Flags of commands that print or open a URL, followed by given flags
+func withOutputFlags(flags ...cli.Flag) []cli.Flag {
+	return withCommonFlags(append([]cli.Flag{printFlag, copyFlag, openFlag, browserFlag, privateFlag}, flags...)...)
+}
+
+var openCommandFlags = withOutputFlags(
+	&cli.StringFlag{
+		Name:        "remote",
+		Aliases:     []string{"r"},
+		Usage:       "name of the git remote to use, skips looking up PRs in \"upstream\" for forks",
+		DefaultText: "origin or \"remote\" from config",
+	},
+	&cli.StringFlag{
+		Name:        "branch",
+		Aliases:     []string{"b"},
+		Usage:       "branch to open PR for",
+		DefaultText: "current branch",
+	},
+	&cli.BoolFlag{
+		Name:  "json",
+		Usage: "print PR details as JSON, status messages go to stderr",
+	},
+	&cli.BoolFlag{
+		Name:  "force-pr",
+		Usage: "look up PR even on main branch instead of opening home page",
+	},
+	&cli.BoolFlag{
+		Name:  "no-cache",
+		Usage: "look up PR in the API even if it was found recently",
+	},
+	&cli.BoolFlag{
+		Name:  "files",
+		Usage: "open the PR diff instead of the conversation tab",
+	},
+	&cli.BoolFlag{
+		Name:    "create-if-missing",
+		Aliases: []string{"web"},
+		Usage:   "open new PR page if no PR is found for the branch",
+	},
+	&cli.StringFlag{
+		Name:  "base",
+		Usage: "only match PRs targeting given branch, when more PRs are open from the branch",
+	},
+	&cli.BoolFlag{
+		Name:  "all",
+		Usage: "open every PR of the branch, e.g. stacked PRs, instead of one",
+	},
+	&cli.BoolFlag{
+		Name:  "wait",
+		Usage: "poll until a PR is opened for the branch, e.g. right after pushing",
+	},
+	&cli.DurationFlag{
+		Name:        "wait-interval",
+		Usage:       "time between lookups with --wait",
+		DefaultText: "5s",
+	},
+	&cli.DurationFlag{
+		Name:        "wait-timeout",
+		Usage:       "maximum time to wait for a PR with --wait",
+		DefaultText: "5m",
+	},
+	&cli.BoolFlag{
+		Name:  "web-login",
+		Usage: "open PR list filtered by the branch when token is not set, instead of failing",
+	},
+	&cli.StringFlag{
+		Name:  "body",
+		Usage: "pre-fill description on the new PR page when no PR is found (GitHub and GitLab)",
+	},
+	&cli.BoolFlag{
+		Name:  "first",
+		Usage: "open the first PR when more PRs match the branch instead of asking",
+	},
 	&cli.BoolFlag{
-		Name:    "print",
-		Aliases: []string{"p"},
-		Usage:   "print URL instead of opening in browser",
+		Name:    "app",
+		Aliases: []string{"open-app"},
+		Usage:   "open the branch in GitHub Desktop instead of the browser",
 	},
+	&cli.BoolFlag{
+		Name:    "review-requested",
+		Aliases: []string{"reviewer"},
+		Usage:   "open the first PR waiting for your review instead of the PR of the branch",
+	},
+	&cli.StringFlag{
+		Name:  "format",
+		Usage: "print PR with a Go template, e.g. '{{.Number}} {{.Title}}', or preset \"url\" or \"markdown\"",
+	},
+	&cli.BoolFlag{
+		Name:  "probe",
+		Usage: "detect provider of an unknown self-hosted host by requesting its API",
+	},
+	&cli.BoolFlag{
+		Name:  "issue",
+		Usage: "open the issue referenced in the branch name, e.g. issue-123, instead of the PR",
+	},
+	&cli.BoolFlag{
+		Name:  "no-push-check",
+		Usage: "don't warn about commits which are not pushed yet",
+	},
+	&cli.BoolFlag{
+		Name:  "all-remotes",
+		Usage: "look up PR in every remote until one is found",
+	},
+	&cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "print the provider, API request and URL that would be used, without making requests",
+	},
+)
+
+// Providers accepted by auth and logout commands
+var providerNames = []string{"github", "gitlab", "bitbucket", "gitea", "azure"}
+
+func isProvider(name string) bool {
+	for _, provider := range providerNames {
+		if provider == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Open command takes a PR number or a repository path. Returns the path
+// ("." by default) and the number (0 if not given).
+func openArgs(c *cli.Context) (string, int) {
+	if c.NArg() > 1 {
+		fmt.Println("Please specify a single PR number or repository path, e.g. `pro open 42`")
+		os.Exit(1)
+	}
+
+	arg := c.Args().First()
+	if arg == "" {
+		return ".", 0
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return arg, 0
+	}
+
+	if number <= 0 {
+		fmt.Println("PR number must be positive")
+		os.Exit(1)
+	}
+
+	return ".", number
+}
+
+func openOptions(c *cli.Context) commands.OpenOptions {
+	return commands.OpenOptions{
+		Remote:          c.String("remote"),
+		Branch:          c.String("branch"),
+		OutputOptions:   outputOptions(c),
+		ForcePR:         c.Bool("force-pr"),
+		JSON:            c.Bool("json"),
+		NoCache:         c.Bool("no-cache"),
+		CreateIfMissing: c.Bool("create-if-missing"),
+		Files:           c.Bool("files"),
+		DryRun:          c.Bool("dry-run"),
+		App:             c.Bool("app"),
+		First:           c.Bool("first"),
+		Base:            c.String("base"),
+		AllRemotes:      c.Bool("all-remotes"),
+		NoPushCheck:     c.Bool("no-push-check"),
+		ReviewRequested: c.Bool("review-requested"),
+		Issue:           c.Bool("issue"),
+		Probe:           c.Bool("probe"),
+		Format:          c.String("format"),
+		All:             c.Bool("all"),
+		Body:            c.String("body"),
+		WebLogin:        c.Bool("web-login"),
+		Wait:            c.Bool("wait"),
+		WaitInterval:    c.Duration("wait-interval"),
+		WaitTimeout:     c.Duration("wait-timeout"),
+	}
+}
+
+// Action of the open command, which is also the default action
+func openAction(c *cli.Context) error {
+	repoPath, number := openArgs(c)
+
+	options := openOptions(c)
+	options.Number = number
+
+	if options.All && (options.Copy || options.First) {
+		fmt.Println("--all can't be used with --copy or --first")
+		os.Exit(1)
+	}
+
+	if options.Format != "" && options.JSON {
+		fmt.Println("Please use either --format or --json")
+		os.Exit(1)
+	}
+
+	if options.Issue && (options.ReviewRequested || options.AllRemotes || options.Files || number != 0 || options.DryRun) {
+		fmt.Println("--issue can't be used with --review-requested, --all-remotes, --files, --dry-run or a PR number")
+		os.Exit(1)
+	}
+
+	if options.ReviewRequested && (options.AllRemotes || number != 0 || options.DryRun) {
+		fmt.Println("--review-requested can't be used with --all-remotes, --dry-run or a PR number")
+		os.Exit(1)
+	}
+
+	if (c.IsSet("wait-interval") && options.WaitInterval <= 0) || (c.IsSet("wait-timeout") && options.WaitTimeout <= 0) {
+		fmt.Println("--wait-interval and --wait-timeout must be positive, e.g. 5s")
+		os.Exit(1)
+	}
+
+	if options.Wait && (options.AllRemotes || options.ReviewRequested || options.Issue || number != 0 || options.DryRun) {
+		fmt.Println("--wait can't be used with --all-remotes, --review-requested, --issue, --dry-run or a PR number")
+		os.Exit(1)
+	}
+
+	if options.AllRemotes && (options.Remote != "" || number != 0 || options.DryRun) {
+		fmt.Println("--all-remotes can't be used with --remote, --dry-run or a PR number")
+		os.Exit(1)
+	}
+
+	commands.Open(repoPath, options)
+
+	return nil
+}
+
+// Output flags, with "default_action" from config used when none of them is given
+func outputOptions(c *cli.Context) commands.OutputOptions {
+	options := commands.OutputOptions{
+		Print:   c.Bool("print"),
+		Copy:    c.Bool("copy"),
+		Browser: c.String("browser"),
+		Private: c.Bool("private"),
+	}
+
+	explicit := options.Print || options.Copy || options.Private || c.IsSet("browser") || c.Bool("open")
+
+	action := config.Get().DefaultAction
+	if action != "" && action != "open" && action != "print" {
+		color.Red("Invalid default_action \"%s\" in config, use open or print.", action)
+		os.Exit(1)
+	}
+
+	if action == "print" && !explicit {
+		options.Print = true
+	}
+
+	return options
 }
 
 func main() {
+	// -v is used for --verbose
+	cli.VersionFlag = &cli.BoolFlag{
+		Name:  "version",
+		Usage: "print the version",
+	}
+
 	// cli library API example:
 	// https://github.com/urfave/cli/blob/main/docs/v2/manual.md#full-api-example
 	app := &cli.App{
-		Name:    "pro",
-		Usage:   "Pull Request Opener",
-		Version: "v0.1.5",
-		Flags:   openCommandFlags,
+		Name:      "pro",
+		Usage:     "Pull Request Opener",
+		Version:   "v0.1.5",
+		ArgsUsage: "[number|path]",
+		Flags:     openCommandFlags,
+		// Used by scripts printed by the completion command
+		EnableBashCompletion: true,
+		Before:               applyCommonFlags,
 		Commands: []*cli.Command{
 			{
-				Name:      "auth",
-				ArgsUsage: "[gitlab|github]",
-				Usage:     "Authorize GitLab or GitHub",
-				UsageText: "pro auth gitlab\npro login github",
+				Name:         "auth",
+				ArgsUsage:    "[gitlab|github|bitbucket|gitea|azure]",
+				Usage:        "Authorize GitLab, GitHub, Bitbucket, Gitea or Azure DevOps",
+				UsageText:    "pro auth gitlab\npro login github\npro auth github --host github.acme.com\npro auth status",
+				BashComplete: completeProviders,
+				Subcommands: []*cli.Command{
+					{
+						Name:  "status",
+						Usage: "Show whether tokens of all providers and configured hosts are set and valid",
+						Flags: withCommonFlags(),
+						Action: func(c *cli.Context) error {
+							commands.AuthStatus()
+							return nil
+						},
+					},
+				},
+				Flags: withCommonFlags(
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "self-hosted GitLab, GitHub Enterprise or Gitea host name",
+					},
+					&cli.BoolFlag{
+						Name:  "token",
+						Usage: "paste personal access token instead of logging in with browser (GitHub)",
+					},
+				),
 				Action: func(c *cli.Context) error {
-					if c.NArg() != 1 {
-						fmt.Println("Please specify provider (github or gitlab)")
+					provider := c.Args().Get(0)
+
+					if c.NArg() != 1 || !isProvider(provider) {
+						fmt.Println("Please specify provider (github, gitlab, bitbucket, gitea or azure)")
 						os.Exit(1)
 					}
 
+					commands.Auth(provider, commands.AuthOptions{
+						Host:  c.String("host"),
+						Token: c.Bool("token"),
+					})
+
+					return nil
+				},
+			},
+			{
+				Name:         "logout",
+				ArgsUsage:    "[gitlab|github|bitbucket|gitea|azure]",
+				Usage:        "Remove stored tokens, all of them if no provider is given",
+				UsageText:    "pro logout\npro logout github\npro logout --host github.acme.com",
+				BashComplete: completeProviders,
+				Flags: withCommonFlags(
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "self-hosted instance to remove token for",
+					},
+				),
+				Action: func(c *cli.Context) error {
 					provider := c.Args().Get(0)
 
-					if provider != "gitlab" && provider != "github" {
-						fmt.Println("Please specify provider (github or gitlab)")
+					if c.NArg() > 1 || (provider != "" && !isProvider(provider)) {
+						fmt.Println("Please specify provider (github, gitlab, bitbucket, gitea or azure) or none to remove all tokens")
 						os.Exit(1)
 					}
 
-					commands.Auth(provider)
+					commands.Logout(provider, c.String("host"))
 
 					return nil
 				},
 			},
 			{
-				Name:  "open",
-				Usage: "Open PR page in browser (default action)",
-				Flags: openCommandFlags,
+				Name:         "whoami",
+				ArgsUsage:    "[gitlab|github|bitbucket|gitea|azure]",
+				Usage:        "Show user and scopes of the stored token, for the current repository if no provider is given",
+				UsageText:    "pro whoami\npro whoami github\npro whoami --host gitlab.acme.com gitlab",
+				BashComplete: completeProviders,
+				Flags: withCommonFlags(
+					remoteFlag,
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "self-hosted instance to check token for",
+					},
+				),
 				Action: func(c *cli.Context) error {
-					commands.Open(".", c.Bool("print"))
+					provider := c.Args().Get(0)
+
+					if c.NArg() > 1 || (provider != "" && !isProvider(provider)) {
+						fmt.Println("Please specify provider (github, gitlab, bitbucket, gitea or azure) or none to use the current repository")
+						os.Exit(1)
+					}
+
+					commands.Whoami(provider, commands.WhoamiOptions{
+						Host:   c.String("host"),
+						Remote: c.String("remote"),
+					})
 					return nil
 				},
 			},
-		},
-		Action: func(c *cli.Context) error {
-			commands.Open(".", c.Bool("print"))
+			{
+				Name:      "doctor",
+				Usage:     "Check git, config file, browser, tokens and connection to each host",
+				UsageText: "pro doctor",
+				Flags:     withCommonFlags(),
+				Action: func(c *cli.Context) error {
+					commands.Doctor()
+					return nil
+				},
+			},
+			{
+				Name:      "checkout",
+				ArgsUsage: "<number>",
+				Usage:     "Fetch PR branch and switch to it",
+				UsageText: "pro checkout 42",
+				Flags:     withCommonFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					number, err := strconv.Atoi(strings.TrimPrefix(c.Args().First(), "#"))
+					if err != nil || number <= 0 || c.NArg() > 1 {
+						fmt.Println("Please specify a single PR number, e.g. `pro checkout 42`")
+						os.Exit(1)
+					}
+
+					commands.Checkout(".", number, commands.CheckoutOptions{
+						Remote: c.String("remote"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "open",
+				ArgsUsage: "[number|path]",
+				Usage:     "Open PR page in browser (default action)",
+				UsageText: "pro open\npro open 42\npro open ~/code/other-repo",
+				Flags:     openCommandFlags,
+				Action:    openAction,
+			},
+			{
+				Name:      "create",
+				Usage:     "Create PR for current branch and open it in browser",
+				UsageText: "pro create\npro create --title \"Fix login\" --base develop",
+				Flags: withOutputFlags(
+					remoteFlag,
+					&cli.StringFlag{
+						Name:        "title",
+						Aliases:     []string{"t"},
+						Usage:       "PR title",
+						DefaultText: "last commit subject",
+					},
+					&cli.StringFlag{
+						Name:  "body",
+						Usage: "PR description",
+					},
+					&cli.StringFlag{
+						Name:        "base",
+						Usage:       "branch the PR should be merged into",
+						DefaultText: "repository default branch",
+					},
+					&cli.BoolFlag{
+						Name:  "draft",
+						Usage: "create PR as a draft",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					commands.Create(".", commands.CreateOptions{
+						Remote:        c.String("remote"),
+						Title:         c.String("title"),
+						Body:          c.String("body"),
+						Base:          c.String("base"),
+						Draft:         c.Bool("draft"),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:    "pipeline",
+				Aliases: []string{"ci"},
+				Usage:   "Open CI status of current branch's PR in browser",
+				Flags:   withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					commands.Pipeline(".", commands.PipelineOptions{
+						Remote:        c.String("remote"),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "issues",
+				ArgsUsage: "[number]",
+				Usage:     "Open issues of the repository in browser",
+				UsageText: "pro issues\npro issues 42",
+				Flags: withOutputFlags(
+					remoteFlag,
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print URL as JSON, status messages go to stderr",
+					},
+					&cli.BoolFlag{
+						Name:  "linked",
+						Usage: "open issues closed by the PR of the current branch, e.g. with \"Fixes #12\"",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					if c.Bool("linked") && c.NArg() > 0 {
+						fmt.Println("Please use either an issue number or --linked")
+						os.Exit(1)
+					}
+
+					var number int
+					if c.NArg() > 0 {
+						n, err := strconv.Atoi(strings.TrimPrefix(c.Args().First(), "#"))
+						if err != nil || n <= 0 || c.NArg() > 1 {
+							fmt.Println("Please specify a single issue number, e.g. `pro issues 42`")
+							os.Exit(1)
+						}
+
+						number = n
+					}
+
+					commands.Issues(".", commands.IssuesOptions{
+						Remote:        c.String("remote"),
+						Number:        number,
+						OutputOptions: outputOptions(c),
+						JSON:          c.Bool("json"),
+						Linked:        c.Bool("linked"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "link",
+				ArgsUsage: "<file>[:<line>[-<line>]]",
+				Usage:     "Open permalink to a file or lines at the current commit",
+				UsageText: "pro link main.go\npro link -c commands/open.go:10-20",
+				Flags:     withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						fmt.Println("Please specify file, e.g. `pro link main.go:10-20`")
+						os.Exit(1)
+					}
+
+					commands.Link(".", c.Args().Get(0), commands.LinkOptions{
+						Remote:        c.String("remote"),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "commit",
+				ArgsUsage: "[sha]",
+				Usage:     "Open the current commit, or given one, in browser",
+				UsageText: "pro commit\npro commit -p a1b2c3d\npro commit v1.2.0",
+				Flags:     withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					if c.NArg() > 1 {
+						fmt.Println("Please specify a single commit, e.g. `pro commit a1b2c3d`")
+						os.Exit(1)
+					}
 
-			return nil
+					commands.Commit(".", c.Args().First(), commands.CommitOptions{
+						Remote:        c.String("remote"),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "branch-url",
+				ArgsUsage: "[branch]",
+				Usage:     "Open files of the current branch, or given one, in browser",
+				UsageText: "pro branch-url\npro branch-url -p feature/login",
+				Flags:     withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					if c.NArg() > 1 {
+						fmt.Println("Please specify a single branch, e.g. `pro branch-url feature/login`")
+						os.Exit(1)
+					}
+
+					commands.BranchURL(".", commands.BranchURLOptions{
+						Remote:        c.String("remote"),
+						Branch:        c.Args().First(),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "compare",
+				ArgsUsage: "[base]",
+				Usage:     "Open comparison of the current branch with the default branch, or given one, in browser",
+				UsageText: "pro compare\npro compare -p develop",
+				Flags:     withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					if c.NArg() > 1 {
+						fmt.Println("Please specify a single base branch, e.g. `pro compare develop`")
+						os.Exit(1)
+					}
+
+					commands.Compare(".", commands.CompareOptions{
+						Remote:        c.String("remote"),
+						Base:          c.Args().First(),
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "releases",
+				ArgsUsage: "[latest]",
+				Usage:     "Open releases of the repository in browser",
+				UsageText: "pro releases\npro releases latest",
+				Flags:     withOutputFlags(remoteFlag),
+				Action: func(c *cli.Context) error {
+					if c.NArg() > 1 || (c.NArg() == 1 && c.Args().First() != "latest") {
+						fmt.Println("Please specify `latest` to open the latest release, e.g. `pro releases latest`")
+						os.Exit(1)
+					}
+
+					commands.Releases(".", commands.ReleasesOptions{
+						Remote:        c.String("remote"),
+						Latest:        c.Args().First() == "latest",
+						OutputOptions: outputOptions(c),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "list",
+				Aliases:   []string{"ls"},
+				Usage:     "List open PRs of the repository",
+				UsageText: "pro list\npro list --mine --json\npro list --limit 10",
+				Flags: withCommonFlags(
+					remoteFlag,
+					&cli.BoolFlag{
+						Name:  "mine",
+						Usage: "only show PRs authored by you",
+					},
+					&cli.BoolFlag{
+						Name:    "review-requested",
+						Aliases: []string{"reviewer"},
+						Usage:   "only show PRs waiting for your review",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print PRs as JSON, status messages go to stderr",
+					},
+					&cli.IntFlag{
+						Name:        "limit",
+						Aliases:     []string{"n"},
+						Usage:       "show at most this many PRs, most recent first",
+						DefaultText: "all",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					if c.Int("limit") < 0 {
+						fmt.Println("Limit must be positive")
+						os.Exit(1)
+					}
+
+					commands.List(".", commands.ListOptions{
+						Remote:          c.String("remote"),
+						Mine:            c.Bool("mine"),
+						ReviewRequested: c.Bool("review-requested"),
+						JSON:            c.Bool("json"),
+						Limit:           c.Int("limit"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "merge",
+				Usage:     "Merge current branch's PR",
+				UsageText: "pro merge\npro merge --squash --delete-branch\npro merge --rebase --yes",
+				Flags: withCommonFlags(
+					remoteFlag,
+					&cli.BoolFlag{
+						Name:  "merge",
+						Usage: "create a merge commit",
+					},
+					&cli.BoolFlag{
+						Name:  "squash",
+						Usage: "squash commits into one",
+					},
+					&cli.BoolFlag{
+						Name:  "rebase",
+						Usage: "rebase commits onto the base branch, GitHub only",
+					},
+					&cli.BoolFlag{
+						Name:    "delete-branch",
+						Aliases: []string{"d"},
+						Usage:   "delete the branch on the remote after merging",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "merge without asking for confirmation",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					var method string
+					for _, name := range []string{"merge", "squash", "rebase"} {
+						if !c.Bool(name) {
+							continue
+						}
+
+						if method != "" {
+							fmt.Println("Please use only one of --merge, --squash and --rebase")
+							os.Exit(1)
+						}
+						method = name
+					}
+
+					commands.Merge(".", commands.MergeOptions{
+						Remote:       c.String("remote"),
+						Method:       method,
+						DeleteBranch: c.Bool("delete-branch"),
+						Yes:          c.Bool("yes"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "comment",
+				ArgsUsage: "<text>",
+				Usage:     "Post a comment to current branch's PR",
+				UsageText: "pro comment LGTM\npro comment --file review.md\necho LGTM | pro comment -",
+				Flags: withCommonFlags(
+					remoteFlag,
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"F"},
+						Usage:   "read the comment from file",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					if c.IsSet("file") == (c.NArg() > 0) {
+						fmt.Println("Please specify the comment or a file with it, e.g. `pro comment \"LGTM\"`")
+						os.Exit(1)
+					}
+
+					commands.Comment(".", commands.CommentOptions{
+						Remote: c.String("remote"),
+						Body:   strings.Join(c.Args().Slice(), " "),
+						File:   c.String("file"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "Show state, reviews and CI status of current branch's PR",
+				Flags: withCommonFlags(
+					remoteFlag,
+					&cli.IntFlag{
+						Name:        "stale-days",
+						Usage:       "warn if the PR wasn't updated for this many days, negative disables the warning",
+						DefaultText: "stale_days setting or 14",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					commands.Status(".", commands.StatusOptions{
+						Remote:    c.String("remote"),
+						StaleDays: c.Int("stale-days"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:      "config",
+				Usage:     "View and change settings",
+				UsageText: "pro config get browser\npro config set main_branches main,develop\npro config path",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "get",
+						ArgsUsage: "<key>",
+						Usage:     "Print value of a setting",
+						Flags:     []cli.Flag{configFileFlag},
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								fmt.Println("Please specify key, one of:", strings.Join(config.Keys(), ", "))
+								os.Exit(1)
+							}
+
+							commands.ConfigGet(c.Args().Get(0))
+							return nil
+						},
+					},
+					{
+						Name:      "set",
+						ArgsUsage: "<key> <value>",
+						Usage:     "Change a setting, lists are comma-separated and empty value restores the default",
+						Flags:     []cli.Flag{configFileFlag},
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 2 {
+								fmt.Println("Please specify key and value, e.g. `pro config set browser firefox`")
+								os.Exit(1)
+							}
+
+							commands.ConfigSet(c.Args().Get(0), c.Args().Get(1))
+							return nil
+						},
+					},
+					{
+						Name:  "path",
+						Usage: "Print location of the config file",
+						Flags: []cli.Flag{configFileFlag},
+						Action: func(c *cli.Context) error {
+							commands.ConfigPath()
+							return nil
+						},
+					},
+				},
+			},
+			completionCommand,
 		},
+		Action: openAction,
+	}
+
+	for _, command := range app.Commands {
+		command.Before = applyCommonFlags
+		for _, subcommand := range command.Subcommands {
+			subcommand.Before = applyCommonFlags
+		}
 	}
 
 	err := app.Run(os.Args)
@@ -0,0 +1,40 @@
+// Package pro finds pull requests of git repositories like the pro command,
+// returning errors instead of printing them and exiting, so it can be used in
+// other Go programs:
+//
+//	result, err := pro.ResolvePRURL(".", pro.Options{})
+//	if err != nil {
+//		return err
+//	}
+//
+//	if result.URL == "" {
+//		fmt.Println("No pull request, create one at", result.CreateURL)
+//	}
+//
+// Tokens and hosts are read from the pro config file, like in the command.
+// Warnings the command would print, e.g. about unpushed commits, are returned
+// in Result.Warnings.
+package pro
+
+import "github.com/wowu/pro/commands"
+
+type (
+	Options = commands.ResolveOptions
+	Result  = commands.Result
+)
+
+var (
+	ErrNoRepository   = commands.ErrNoRepository
+	ErrNoRemote       = commands.ErrNoRemote
+	ErrBareRepository = commands.ErrBareRepository
+	ErrNoCommits      = commands.ErrNoCommits
+	ErrNoBranch       = commands.ErrNoBranch
+	ErrUnknownHost    = commands.ErrUnknownHost
+	ErrNoToken        = commands.ErrNoToken
+)
+
+// Find URL of the pull request for a branch of the repository at repoPath.
+// When no pull request is open, Result.URL is empty and Result.CreateURL is set.
+func ResolvePRURL(repoPath string, options Options) (Result, error) {
+	return commands.ResolvePRURL(repoPath, options)
+}
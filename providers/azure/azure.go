@@ -0,0 +1,331 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+var ErrRepositoryNotFound = providers.ErrRepositoryNotFound
+var ErrInvalidPath = errors.New("invalid Azure DevOps repository path")
+
+// API base URL of Azure DevOps Services
+const DefaultBaseURL = "https://dev.azure.com"
+
+type ApiResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+func apiGet(url string, token string) (ApiResponse, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	// Personal access tokens are sent as basic auth password with empty username
+	req.SetBasicAuth("", token)
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	return ApiResponse{resp.StatusCode, body}, nil
+}
+
+// Azure DevOps repository location
+type Repository struct {
+	Organization string
+	Project      string
+	Name         string
+}
+
+// Web URL of the repository
+func (r Repository) WebURL() string {
+	return r.ProjectURL() + "/_git/" + url.PathEscape(r.Name)
+}
+
+// Web URL of the project the repository belongs to, work items live there
+func (r Repository) ProjectURL() string {
+	return DefaultBaseURL + "/" + url.PathEscape(r.Organization) + "/" + url.PathEscape(r.Project)
+}
+
+// Check if given remote host belongs to Azure DevOps
+func IsHost(host string) bool {
+	return host == "dev.azure.com" || host == "ssh.dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com")
+}
+
+// Parse repository location from remote host and path. Supported formats:
+//
+//	dev.azure.com            {org}/{project}/_git/{repo}
+//	ssh.dev.azure.com        v3/{org}/{project}/{repo}
+//	vs-ssh.visualstudio.com  v3/{org}/{project}/{repo}
+//	{org}.visualstudio.com   [DefaultCollection/]{project}/_git/{repo}
+func ParseRepository(host string, path string) (Repository, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case host == "ssh.dev.azure.com" || host == "vs-ssh.visualstudio.com":
+		if len(parts) != 4 || parts[0] != "v3" {
+			return Repository{}, ErrInvalidPath
+		}
+
+		return Repository{parts[1], parts[2], parts[3]}, nil
+	case host == "dev.azure.com":
+		if len(parts) != 4 || parts[2] != "_git" {
+			return Repository{}, ErrInvalidPath
+		}
+
+		return Repository{parts[0], parts[1], parts[3]}, nil
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		if len(parts) == 4 && parts[0] == "DefaultCollection" {
+			parts = parts[1:]
+		}
+
+		if len(parts) != 3 || parts[1] != "_git" {
+			return Repository{}, ErrInvalidPath
+		}
+
+		organization := strings.TrimSuffix(host, ".visualstudio.com")
+
+		return Repository{organization, parts[0], parts[2]}, nil
+	default:
+		return Repository{}, ErrInvalidPath
+	}
+}
+
+type ProfileResponse struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+func User(token string) (ProfileResponse, error) {
+	url := "https://app.vssps.visualstudio.com/_apis/profile/profiles/me?api-version=7.0"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ProfileResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	// Invalid tokens get redirected to the sign-in page with 203
+	case http.StatusUnauthorized, http.StatusNonAuthoritativeInfo:
+		return ProfileResponse{}, ErrUnauthorized
+	case http.StatusOK:
+		var profile ProfileResponse
+		err = json.Unmarshal(resp.Body, &profile)
+		if err != nil {
+			return ProfileResponse{}, err
+		}
+
+		return profile, nil
+	default:
+		return ProfileResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type PullRequestResponse struct {
+	ID            int    `json:"pullRequestId"`
+	Title         string `json:"title"`
+	Status        string `json:"status"`
+	SourceRefName string `json:"sourceRefName"`
+	IsDraft       bool   `json:"isDraft"`
+	// Set if the pull request is opened from a fork
+	ForkSource *struct {
+		Repository struct {
+			RemoteURL string `json:"remoteUrl"`
+		} `json:"repository"`
+	} `json:"forkSource"`
+	CreatedBy struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"displayName"`
+	} `json:"createdBy"`
+	// Vote is 0 until the reviewer approves, rejects or waits for the author
+	Reviewers []struct {
+		ID   string `json:"id"`
+		Vote int    `json:"vote"`
+	} `json:"reviewers"`
+	WebURL string `json:"-"`
+}
+
+// Name of the source branch, without "refs/heads/"
+func (p PullRequestResponse) SourceBranch() string {
+	return strings.TrimPrefix(p.SourceRefName, "refs/heads/")
+}
+
+type pullRequestsPage struct {
+	Value []PullRequestResponse `json:"value"`
+}
+
+// API URL of active pull requests from given branch
+func FindPullRequestURL(repository Repository, branch string, base string) string {
+	query := url.Values{}
+	query.Set("searchCriteria.sourceRefName", "refs/heads/"+branch)
+	if base != "" {
+		query.Set("searchCriteria.targetRefName", "refs/heads/"+base)
+	}
+	query.Set("searchCriteria.status", "active")
+	query.Set("api-version", "7.0")
+
+	return DefaultBaseURL + "/" + url.PathEscape(repository.Organization) + "/" + url.PathEscape(repository.Project) +
+		"/_apis/git/repositories/" + url.PathEscape(repository.Name) + "/pullrequests?" + query.Encode()
+}
+
+// Active pull requests from given branch. Non-empty base limits them to those targeting that branch.
+func FindPullRequests(repository Repository, token string, branch string, base string) ([]PullRequestResponse, error) {
+	resp, err := apiGet(FindPullRequestURL(repository, branch, base), token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusNonAuthoritativeInfo:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrRepositoryNotFound
+	case http.StatusOK:
+		var page pullRequestsPage
+		err = json.Unmarshal(resp.Body, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Value) == 0 {
+			return nil, ErrNotFound
+		}
+
+		for i := range page.Value {
+			page.Value[i].WebURL = repository.WebURL() + "/pullrequest/" + fmt.Sprint(page.Value[i].ID)
+		}
+
+		return page.Value, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Pull request lookup on Azure DevOps, see providers.Provider. Host is needed
+// to tell the organization from the repository path.
+type Provider struct {
+	Host string
+}
+
+func (p Provider) FindPullRequests(projectPath string, token string, branch string, base string) ([]providers.PullRequest, error) {
+	repository, err := ParseRepository(p.Host, projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := FindPullRequests(repository, token, branch, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var pullRequests []providers.PullRequest
+	for _, pullRequest := range found {
+		pullRequests = append(pullRequests, providers.PullRequest{
+			Number: pullRequest.ID,
+			Title:  pullRequest.Title,
+			URL:    pullRequest.WebURL,
+			Draft:  pullRequest.IsDraft,
+			Author: pullRequest.CreatedBy.DisplayName,
+		})
+	}
+
+	return pullRequests, nil
+}
+
+// Pull requests fetched per request, pages are requested with $skip
+const pageSize = 100
+
+// Active pull requests, most recent first. Limit caps their number, 0 means all.
+func ListPullRequests(repository Repository, token string, limit int) ([]PullRequestResponse, error) {
+	var pullRequests []PullRequestResponse
+
+	for skip := 0; ; skip += pageSize {
+		query := url.Values{}
+		query.Set("searchCriteria.status", "active")
+		query.Set("$top", fmt.Sprint(pageSize))
+		query.Set("$skip", fmt.Sprint(skip))
+		query.Set("api-version", "7.0")
+
+		url := DefaultBaseURL + "/" + url.PathEscape(repository.Organization) + "/" + url.PathEscape(repository.Project) +
+			"/_apis/git/repositories/" + url.PathEscape(repository.Name) + "/pullrequests?" + query.Encode()
+
+		resp, err := apiGet(url, token)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusNonAuthoritativeInfo:
+			return nil, ErrUnauthorized
+		case http.StatusNotFound:
+			return nil, ErrNotFound
+		case http.StatusOK:
+			var page pullRequestsPage
+			err = json.Unmarshal(resp.Body, &page)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range page.Value {
+				page.Value[i].WebURL = repository.WebURL() + "/pullrequest/" + fmt.Sprint(page.Value[i].ID)
+			}
+
+			pullRequests = append(pullRequests, page.Value...)
+			if limit > 0 && len(pullRequests) >= limit {
+				return pullRequests[:limit], nil
+			}
+
+			if len(page.Value) < pageSize {
+				return pullRequests, nil
+			}
+		default:
+			return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+		}
+	}
+}
+
+func PullRequest(repository Repository, token string, id int) (PullRequestResponse, error) {
+	url := DefaultBaseURL + "/" + url.PathEscape(repository.Organization) + "/" + url.PathEscape(repository.Project) +
+		"/_apis/git/repositories/" + url.PathEscape(repository.Name) + "/pullrequests/" + fmt.Sprint(id) + "?api-version=7.0"
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return PullRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusNonAuthoritativeInfo:
+		return PullRequestResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return PullRequestResponse{}, ErrNotFound
+	case http.StatusOK:
+		var pullRequest PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequest)
+		if err != nil {
+			return PullRequestResponse{}, err
+		}
+
+		pullRequest.WebURL = repository.WebURL() + "/pullrequest/" + fmt.Sprint(pullRequest.ID)
+
+		return pullRequest, nil
+	default:
+		return PullRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
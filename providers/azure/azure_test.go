@@ -0,0 +1,56 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wowu/pro/providers"
+)
+
+// Send requests to dev.azure.com to the test server instead
+func serveAPI(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	defaultTransport, insecure := http.DefaultTransport, providers.Insecure
+	http.DefaultTransport, providers.Insecure = transport, true
+	t.Cleanup(func() {
+		http.DefaultTransport, providers.Insecure = defaultTransport, insecure
+	})
+}
+
+func TestFindPullRequestsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"missing repository", http.StatusNotFound, `{"message":"TF401019"}`, ErrRepositoryNotFound},
+		{"no pull requests", http.StatusOK, `{"value":[]}`, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serveAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+
+			_, err := FindPullRequests(Repository{"org", "project", "repo"}, "token", "feature", "")
+			if !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
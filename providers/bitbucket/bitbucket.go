@@ -0,0 +1,249 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+var ErrRepositoryNotFound = providers.ErrRepositoryNotFound
+
+// API base URL of bitbucket.org
+const DefaultBaseURL = "https://api.bitbucket.org/2.0"
+
+type ApiResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Token is in "username:app_password" format
+func apiGet(url string, token string) (ApiResponse, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	username, password, _ := strings.Cut(token, ":")
+	req.SetBasicAuth(username, password)
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	return ApiResponse{resp.StatusCode, body}, nil
+}
+
+type UserResponse struct {
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+}
+
+func User(token string) (UserResponse, error) {
+	url := DefaultBaseURL + "/user"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return UserResponse{}, ErrUnauthorized
+	case http.StatusOK:
+		var user UserResponse
+		err = json.Unmarshal(resp.Body, &user)
+		if err != nil {
+			return UserResponse{}, err
+		}
+
+		return user, nil
+	default:
+		return UserResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type PullRequestResponse struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Draft  bool   `json:"draft"`
+	Source struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	} `json:"source"`
+	Author struct {
+		UUID        string `json:"uuid"`
+		DisplayName string `json:"display_name"`
+	} `json:"author"`
+	// Only returned in lists when requested with the "fields" parameter
+	Reviewers []struct {
+		UUID string `json:"uuid"`
+	} `json:"reviewers"`
+	Links struct {
+		Html struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// HTTPS clone URL of the repository the pull request is opened from, which is a fork
+// if it's different from the target repository
+func (p PullRequestResponse) SourceCloneURL() string {
+	return "https://bitbucket.org/" + p.Source.Repository.FullName + ".git"
+}
+
+type pullRequestsPage struct {
+	Values []PullRequestResponse `json:"values"`
+	// URL of the next page, empty on the last one
+	Next string `json:"next"`
+}
+
+// Get pages of pull requests following the "next" link. Stops after limit
+// pull requests, 0 means all pages. A failed page is returned as it is.
+func getPullRequestPages(pageURL string, token string, limit int) ([]PullRequestResponse, ApiResponse, error) {
+	var pullRequests []PullRequestResponse
+
+	for pageURL != "" {
+		resp, err := apiGet(pageURL, token)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return nil, resp, err
+		}
+
+		var page pullRequestsPage
+		err = json.Unmarshal(resp.Body, &page)
+		if err != nil {
+			return nil, resp, err
+		}
+
+		pullRequests = append(pullRequests, page.Values...)
+		if limit > 0 && len(pullRequests) >= limit {
+			return pullRequests[:limit], resp, nil
+		}
+
+		pageURL = page.Next
+	}
+
+	return pullRequests, ApiResponse{StatusCode: http.StatusOK}, nil
+}
+
+// API URL of open pull requests from given branch
+func FindPullRequestURL(projectPath string, branch string, base string) string {
+	query := fmt.Sprintf("source.branch.name = %q AND state = \"OPEN\"", branch)
+	if base != "" {
+		query += fmt.Sprintf(" AND destination.branch.name = %q", base)
+	}
+
+	return DefaultBaseURL + "/repositories/" + projectPath + "/pullrequests?q=" + url.QueryEscape(query)
+}
+
+// Open pull requests from given branch. Non-empty base limits them to those targeting that branch.
+func FindPullRequests(projectPath string, token string, branch string, base string) ([]PullRequestResponse, error) {
+	pullRequests, resp, err := getPullRequestPages(FindPullRequestURL(projectPath, branch, base), token, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		// Bitbucket responds the same for missing and private repositories
+		return nil, ErrRepositoryNotFound
+	case http.StatusOK:
+		if len(pullRequests) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return pullRequests, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Pull request lookup on Bitbucket, see providers.Provider
+type Provider struct{}
+
+func (p Provider) FindPullRequests(projectPath string, token string, branch string, base string) ([]providers.PullRequest, error) {
+	found, err := FindPullRequests(projectPath, token, branch, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var pullRequests []providers.PullRequest
+	for _, pullRequest := range found {
+		pullRequests = append(pullRequests, providers.PullRequest{
+			Number: pullRequest.ID,
+			Title:  pullRequest.Title,
+			URL:    pullRequest.Links.Html.Href,
+			Draft:  pullRequest.Draft,
+			Author: pullRequest.Author.DisplayName,
+		})
+	}
+
+	return pullRequests, nil
+}
+
+// Open pull requests, most recent first. Limit caps their number, 0 means all.
+func ListPullRequests(projectPath string, token string, limit int) ([]PullRequestResponse, error) {
+	url := DefaultBaseURL + "/repositories/" + projectPath + "/pullrequests?state=OPEN&pagelen=50&fields=%2Bvalues.reviewers"
+
+	pullRequests, resp, err := getPullRequestPages(url, token, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusOK:
+		return pullRequests, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+func PullRequest(projectPath string, token string, id int) (PullRequestResponse, error) {
+	url := DefaultBaseURL + "/repositories/" + projectPath + "/pullrequests/" + fmt.Sprint(id)
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return PullRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return PullRequestResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return PullRequestResponse{}, ErrNotFound
+	case http.StatusOK:
+		var pullRequest PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequest)
+		if err != nil {
+			return PullRequestResponse{}, err
+		}
+
+		return pullRequest, nil
+	default:
+		return PullRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
@@ -0,0 +1,140 @@
+package gerrit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+
+// Gerrit prefixes JSON responses with this line to prevent XSSI
+var jsonPrefix = []byte(")]}'")
+
+// Change-Id footer added to commit messages by the Gerrit commit-msg hook
+var changeIDFooter = regexp.MustCompile(`(?m)^Change-Id:\s*(I[0-9a-f]{40})\s*$`)
+
+type ApiResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Token is in "username:http_password" format. Without token, requests are
+// anonymous, which is enough for public changes. Authenticated requests go to
+// the "/a" prefix.
+func apiGet(baseURL string, path string, token string) (ApiResponse, error) {
+	if token != "" {
+		baseURL += "/a"
+	}
+
+	req, err := http.NewRequest("GET", baseURL+path, nil)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	if token != "" {
+		username, password, _ := strings.Cut(token, ":")
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	return ApiResponse{resp.StatusCode, bytes.TrimPrefix(body, jsonPrefix)}, nil
+}
+
+type ChangeResponse struct {
+	Number         int    `json:"_number"`
+	ChangeID       string `json:"change_id"`
+	Project        string `json:"project"`
+	Branch         string `json:"branch"`
+	Subject        string `json:"subject"`
+	Status         string `json:"status"`
+	WorkInProgress bool   `json:"work_in_progress"`
+}
+
+// Web URL of the change
+func (c ChangeResponse) URL(baseURL string) string {
+	return baseURL + "/c/" + c.Project + "/+/" + fmt.Sprint(c.Number)
+}
+
+// Change-Id from the footer of a commit message. The last one is used, as
+// the footer is at the end of the message.
+func ParseChangeID(message string) (string, bool) {
+	matches := changeIDFooter.FindAllStringSubmatch(message, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	return matches[len(matches)-1][1], true
+}
+
+// Changes with given Change-Id in the project, one for each target branch it
+// was cherry-picked to. Merged and abandoned changes are included.
+func FindChanges(baseURL string, projectPath string, token string, changeID string) ([]ChangeResponse, error) {
+	query := fmt.Sprintf("change:%s project:%s", changeID, projectPath)
+
+	resp, err := apiGet(baseURL, "/changes/?q="+url.QueryEscape(query), token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusOK:
+		var changes []ChangeResponse
+		err = json.Unmarshal(resp.Body, &changes)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(changes) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return changes, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Change with given number
+func Change(baseURL string, token string, number int) (ChangeResponse, error) {
+	resp, err := apiGet(baseURL, "/changes/"+fmt.Sprint(number), token)
+	if err != nil {
+		return ChangeResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ChangeResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return ChangeResponse{}, ErrNotFound
+	case http.StatusOK:
+		var change ChangeResponse
+		err = json.Unmarshal(resp.Body, &change)
+		return change, err
+	default:
+		return ChangeResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
@@ -0,0 +1,273 @@
+package gitea
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+var ErrRepositoryNotFound = providers.ErrRepositoryNotFound
+
+// API base URL of codeberg.org, the public Forgejo instance
+const CodebergBaseURL = "https://codeberg.org/api/v1"
+
+type ApiResponse struct {
+	StatusCode int
+	Body       []byte
+	Header     http.Header
+}
+
+func apiGet(url string, token string) (ApiResponse, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	req.Header.Set("Authorization", "token "+token)
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+
+	return ApiResponse{resp.StatusCode, body, resp.Header}, nil
+}
+
+// Get pages of a list endpoint following the Link header, joined into a single
+// JSON array. Stops after limit items, 0 means all pages. A failed page is
+// returned as it is.
+func apiGetPages(pageURL string, token string, limit int) (ApiResponse, error) {
+	var items []json.RawMessage
+
+	for pageURL != "" {
+		resp, err := apiGet(pageURL, token)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+
+		var page []json.RawMessage
+		err = json.Unmarshal(resp.Body, &page)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		items = append(items, page...)
+		if limit > 0 && len(items) >= limit {
+			items = items[:limit]
+			break
+		}
+
+		pageURL = providers.NextPageURL(resp.Header)
+	}
+
+	body, err := json.Marshal(items)
+	return ApiResponse{http.StatusOK, body, nil}, err
+}
+
+type UserResponse struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+}
+
+func User(baseURL string, token string) (UserResponse, error) {
+	url := baseURL + "/user"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return UserResponse{}, ErrUnauthorized
+	case http.StatusOK:
+		var user UserResponse
+		err = json.Unmarshal(resp.Body, &user)
+		if err != nil {
+			return UserResponse{}, err
+		}
+
+		return user, nil
+	default:
+		return UserResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type PullRequestResponse struct {
+	ID     int    `json:"id"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+	Body    string `json:"body"`
+	HtmlURL string `json:"html_url"`
+}
+
+// Gitea marks drafts with a title prefix, "WIP:" or "[WIP]" by default
+func (p PullRequestResponse) IsDraft() bool {
+	title := strings.ToLower(p.Title)
+	return strings.HasPrefix(title, "wip:") || strings.HasPrefix(title, "[wip]")
+}
+
+// Gitea can't filter pull requests by head branch, so open pull requests
+// are fetched and matched by head ref
+func FindPullRequests(baseURL string, projectPath string, token string, branch string, base string) ([]PullRequestResponse, error) {
+	pullRequests, err := ListPullRequests(baseURL, projectPath, token, 0)
+	if errors.Is(err, ErrNotFound) {
+		// Listing pull requests only fails this way if the repository is missing
+		return nil, ErrRepositoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found []PullRequestResponse
+	for _, pullRequest := range pullRequests {
+		if pullRequest.Head.Ref == branch && (base == "" || pullRequest.Base.Ref == base) {
+			found = append(found, pullRequest)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return found, nil
+}
+
+// Pull request lookup on Gitea or Forgejo, see providers.Provider
+type Provider struct {
+	BaseURL string
+}
+
+func (p Provider) FindPullRequests(projectPath string, token string, branch string, base string) ([]providers.PullRequest, error) {
+	found, err := FindPullRequests(p.BaseURL, projectPath, token, branch, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var pullRequests []providers.PullRequest
+	for _, pullRequest := range found {
+		pullRequests = append(pullRequests, providers.PullRequest{
+			Number: pullRequest.Number,
+			Title:  pullRequest.Title,
+			URL:    pullRequest.HtmlURL,
+			Draft:  pullRequest.IsDraft(),
+			Author: pullRequest.User.Login,
+		})
+	}
+
+	return pullRequests, nil
+}
+
+// API URL of open pull requests
+func ListPullRequestsURL(baseURL string, projectPath string) string {
+	return baseURL + "/repos/" + projectPath + "/pulls?state=open&limit=50"
+}
+
+// Open pull requests, most recent first. Limit caps their number, 0 means all.
+func ListPullRequests(baseURL string, projectPath string, token string, limit int) ([]PullRequestResponse, error) {
+	resp, err := apiGetPages(ListPullRequestsURL(baseURL, projectPath), token, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusOK:
+		var pullRequests []PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		return pullRequests, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+func PullRequest(baseURL string, projectPath string, token string, number int) (PullRequestResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/pulls/" + fmt.Sprint(number)
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return PullRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return PullRequestResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return PullRequestResponse{}, ErrNotFound
+	case http.StatusOK:
+		var pullRequest PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequest)
+		if err != nil {
+			return PullRequestResponse{}, err
+		}
+
+		return pullRequest, nil
+	default:
+		return PullRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type ReleaseResponse struct {
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	HtmlURL string `json:"html_url"`
+}
+
+// Most recent published release, drafts and pre-releases are skipped
+func LatestRelease(baseURL string, projectPath string, token string) (ReleaseResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/releases/latest"
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ReleaseResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return ReleaseResponse{}, ErrNotFound
+	case http.StatusOK:
+		var release ReleaseResponse
+		err = json.Unmarshal(resp.Body, &release)
+		if err != nil {
+			return ReleaseResponse{}, err
+		}
+
+		return release, nil
+	default:
+		return ReleaseResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
@@ -0,0 +1,140 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrInvalidPrivateKey = errors.New("invalid private key, expected PEM encoded RSA key")
+
+type installationTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// Create installation access token of a GitHub App, valid for one hour.
+// The private key is the PEM file downloaded from the app settings.
+func InstallationToken(baseURL string, appID string, privateKey []byte, installationID string) (string, error) {
+	jwt, err := appJWT(appID, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%s/access_tokens", baseURL, installationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// App JWT is only accepted as a bearer token
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var token installationTokenResponse
+		err = json.Unmarshal(body, &token)
+		return token.Token, err
+	case http.StatusUnauthorized:
+		return "", ErrUnauthorized
+	case http.StatusNotFound:
+		return "", ErrNotFound
+	default:
+		return "", errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// JSON Web Token authenticating as the app, signed with its private key
+func appJWT(appID string, privateKey []byte) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Issued in the past to allow for clock drift, GitHub accepts at most 10 minutes
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + encoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// GitHub generates PKCS#1 keys, PKCS#8 is accepted as well for converted keys
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	return key, nil
+}
+
+// Check installation token by listing repositories it can access, as
+// installation tokens can't read /user
+func CheckInstallationToken(baseURL string, token string) error {
+	resp, err := apiGet(baseURL+"/installation/repositories?per_page=1", token)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
@@ -0,0 +1,132 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wowu/pro/providers"
+)
+
+var ErrAccessDenied = errors.New("access denied")
+var ErrDeviceCodeExpired = errors.New("device code expired")
+
+// Client ID of the OAuth app used for device flow login. Release builds set it from
+// the PRO_GITHUB_OAUTH_CLIENT_ID secret with
+// -ldflags "-X github.com/wowu/pro/providers/github.OAuthClientID=<id>".
+var OAuthClientID = ""
+
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+	Interval    int    `json:"interval"`
+}
+
+// OAuth endpoints don't use the API base URL, they live on the web host
+func oauthPost(host string, path string, form url.Values, response interface{}) error {
+	req, err := http.NewRequest("POST", "https://"+host+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := providers.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected response: " + resp.Status)
+	}
+
+	return json.Unmarshal(body, response)
+}
+
+// Start OAuth device flow. User has to enter the returned user code at verification URI.
+func RequestDeviceCode(host string, clientID string, scope string) (DeviceCode, error) {
+	var code DeviceCode
+	err := oauthPost(host, "/login/device/code", url.Values{"client_id": {clientID}, "scope": {scope}}, &code)
+
+	return code, err
+}
+
+// Polling interval used when the server doesn't send one (RFC 8628, section 3.2)
+const defaultPollInterval = 5 * time.Second
+
+// Poll until the user authorizes the device and return the access token
+func WaitForAccessToken(host string, clientID string, code DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	form := url.Values{
+		"client_id":   {clientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		var response accessTokenResponse
+		err := oauthPost(host, "/login/oauth/access_token", form, &response)
+		if err != nil {
+			return "", err
+		}
+
+		switch response.Error {
+		case "":
+			return response.AccessToken, nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval = slowDownInterval(interval, response.Interval)
+		case "expired_token":
+			return "", ErrDeviceCodeExpired
+		case "access_denied":
+			return "", ErrAccessDenied
+		default:
+			return "", errors.New(response.Error)
+		}
+	}
+
+	return "", ErrDeviceCodeExpired
+}
+
+// Interval after a slow_down response, which asks to poll less often. Without
+// interval in the response it's increased by 5 seconds (RFC 8628, section 3.5),
+// and it never gets shorter than the current one.
+func slowDownInterval(current time.Duration, interval int) time.Duration {
+	next := time.Duration(interval) * time.Second
+	if interval == 0 {
+		next = current + 5*time.Second
+	}
+
+	if next < current {
+		return current
+	}
+
+	return next
+}
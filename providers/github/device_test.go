@@ -0,0 +1,27 @@
+package github
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowDownInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  time.Duration
+		interval int
+		want     time.Duration
+	}{
+		{"interval in response", 5 * time.Second, 10, 10 * time.Second},
+		{"no interval in response", 5 * time.Second, 0, 10 * time.Second},
+		{"shorter interval in response", 10 * time.Second, 5, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := slowDownInterval(test.current, test.interval); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -1,51 +1,165 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/wowu/pro/providers"
 )
 
-var ErrUnauthorized = errors.New("unauthorized")
-var ErrNotFound = errors.New("not found")
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+var ErrRepositoryNotFound = providers.ErrRepositoryNotFound
+var ErrRateLimited = errors.New("rate limited")
+
+// API base URL of github.com
+const DefaultBaseURL = "https://api.github.com"
 
 type ApiResponse struct {
 	StatusCode int
 	Body       []byte
+	Header     http.Header
 }
 
 func apiGet(url string, token string) (ApiResponse, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return apiRequest("GET", url, token, nil)
+}
+
+func apiPost(url string, token string, payload interface{}) (ApiResponse, error) {
+	return apiRequest("POST", url, token, payload)
+}
+
+func apiPut(url string, token string, payload interface{}) (ApiResponse, error) {
+	return apiRequest("PUT", url, token, payload)
+}
+
+func apiDelete(url string, token string) (ApiResponse, error) {
+	return apiRequest("DELETE", url, token, nil)
+}
+
+func apiRequest(method string, url string, token string, payload interface{}) (ApiResponse, error) {
+	var requestBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		requestBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, requestBody)
 	if err != nil {
 		return ApiResponse{}, err
 	}
 
 	req.Header.Set("Authorization", "token "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := providers.Do(req)
 	if err != nil {
 		return ApiResponse{}, err
 	}
+	defer resp.Body.Close()
+
+	if isRateLimited(resp) {
+		return ApiResponse{}, rateLimitError(resp)
+	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return ApiResponse{}, err
 	}
 
-	return ApiResponse{resp.StatusCode, body}, nil
+	return ApiResponse{resp.StatusCode, body, resp.Header}, nil
+}
+
+// Get pages of a list endpoint following the Link header, joined into a single
+// JSON array. Stops after limit items, 0 means all pages. A failed page is
+// returned as it is.
+func apiGetPages(pageURL string, token string, limit int) (ApiResponse, error) {
+	var items []json.RawMessage
+
+	for pageURL != "" {
+		resp, err := apiGet(pageURL, token)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+
+		var page []json.RawMessage
+		err = json.Unmarshal(resp.Body, &page)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		items = append(items, page...)
+		if limit > 0 && len(items) >= limit {
+			items = items[:limit]
+			break
+		}
+
+		pageURL = providers.NextPageURL(resp.Header)
+	}
+
+	body, err := json.Marshal(items)
+	return ApiResponse{http.StatusOK, body, nil}, err
+}
+
+// Returned when the API rate limit is exceeded, Reset is when it's lifted
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e RateLimitError) Error() string {
+	return "API rate limit exceeded"
+}
+
+func (e RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Primary rate limit responds with 403, secondary one may also use 429
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	return resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+}
+
+func rateLimitError(resp *http.Response) RateLimitError {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return RateLimitError{time.Now().Add(time.Duration(seconds) * time.Second)}
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return RateLimitError{}
+	}
+
+	return RateLimitError{time.Unix(reset, 0)}
 }
 
 type UserResponse struct {
-	ID int `json:"id"`
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	// OAuth scopes of the token, nil for fine-grained tokens which have permissions instead
+	Scopes []string `json:"-"`
 }
 
-func User(token string) (UserResponse, error) {
-	url := "https://api.github.com/user"
+func User(baseURL string, token string) (UserResponse, error) {
+	url := baseURL + "/user"
 	resp, err := apiGet(url, token)
 	if err != nil {
 		return UserResponse{}, err
@@ -61,6 +175,15 @@ func User(token string) (UserResponse, error) {
 			return UserResponse{}, err
 		}
 
+		if _, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; ok {
+			user.Scopes = []string{}
+			for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+				if scope = strings.TrimSpace(scope); scope != "" {
+					user.Scopes = append(user.Scopes, scope)
+				}
+			}
+		}
+
 		return user, nil
 	default:
 		return UserResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
@@ -68,40 +191,530 @@ func User(token string) (UserResponse, error) {
 }
 
 type PullRequestResponse struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
-	State string `json:"state"`
-	Head  struct {
+	ID     int    `json:"id"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Draft  bool   `json:"draft"`
+	Merged bool   `json:"merged"`
+	// Only returned for a single pull request, nil while GitHub computes it
+	Mergeable          *bool  `json:"mergeable"`
+	MergeableState     string `json:"mergeable_state"`
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+	Head struct {
 		Ref string `json:"ref"`
+		SHA string `json:"sha"`
 	} `json:"head"`
-	HtmlURL string `json:"html_url"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Body      string    `json:"body"`
+	HtmlURL   string    `json:"html_url"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-func FindPullRequest(projectPath string, token string, branch string) (PullRequestResponse, error) {
-	userOrOrg := strings.Split(projectPath, "/")[0]
-	url := "https://api.github.com/repos/" + projectPath + "/pulls?state=open&head=" + userOrOrg + ":" + url.QueryEscape(branch)
+// URL of the checks tab
+func (p PullRequestResponse) ChecksURL() string {
+	return p.HtmlURL + "/checks"
+}
 
-	resp, err := apiGet(url, token)
+// API URL of open pull requests from given branch, targeting base branch if it's not empty
+func FindPullRequestURL(baseURL string, projectPath string, branch string, base string) string {
+	head := branch
+	if !strings.Contains(branch, ":") {
+		userOrOrg := strings.Split(projectPath, "/")[0]
+		head = userOrOrg + ":" + branch
+	}
+
+	findURL := baseURL + "/repos/" + projectPath + "/pulls?state=open&per_page=100&head=" + url.QueryEscape(head)
+	if base != "" {
+		findURL += "&base=" + url.QueryEscape(base)
+	}
+
+	return findURL
+}
+
+// Open pull requests from given branch. Branch can be prefixed with owner ("user:branch")
+// to find pull requests opened from a fork. Non-empty base limits them to those targeting that branch.
+func FindPullRequests(baseURL string, projectPath string, token string, branch string, base string) ([]PullRequestResponse, error) {
+	resp, err := apiGetPages(FindPullRequestURL(baseURL, projectPath, branch, base), token, 0)
 	if err != nil {
-		return PullRequestResponse{}, err
+		return nil, err
 	}
 
 	switch resp.StatusCode {
 	case http.StatusUnauthorized:
-		return PullRequestResponse{}, ErrUnauthorized
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		// GitHub hides private repositories the token can't access the same way
+		return nil, ErrRepositoryNotFound
 	case http.StatusOK:
 		var pullRequests []PullRequestResponse
 		err = json.Unmarshal(resp.Body, &pullRequests)
 		if err != nil {
-			return PullRequestResponse{}, err
+			return nil, err
 		}
 
 		if len(pullRequests) == 0 {
-			return PullRequestResponse{}, ErrNotFound
+			return nil, ErrNotFound
 		}
 
-		return pullRequests[0], nil
+		return pullRequests, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Pull request lookup on GitHub, see providers.Provider
+type Provider struct {
+	BaseURL string
+}
+
+func (p Provider) FindPullRequests(projectPath string, token string, branch string, base string) ([]providers.PullRequest, error) {
+	found, err := FindPullRequests(p.BaseURL, projectPath, token, branch, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var pullRequests []providers.PullRequest
+	for _, pullRequest := range found {
+		pullRequests = append(pullRequests, providers.PullRequest{
+			Number:    pullRequest.Number,
+			Title:     pullRequest.Title,
+			URL:       pullRequest.HtmlURL,
+			Draft:     pullRequest.Draft,
+			Author:    pullRequest.User.Login,
+			HeadSHA:   pullRequest.Head.SHA,
+			ChecksURL: pullRequest.ChecksURL(),
+		})
+	}
+
+	return pullRequests, nil
+}
+
+// Open pull requests, most recent first. Limit caps their number, 0 means all.
+func ListPullRequests(baseURL string, projectPath string, token string, limit int) ([]PullRequestResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/pulls?state=open&per_page=100"
+
+	resp, err := apiGetPages(url, token, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusOK:
+		var pullRequests []PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		return pullRequests, nil
+	default:
+		return nil, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Error message returned by the API, e.g. for validation errors
+func apiError(body []byte) error {
+	var response struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err != nil || response.Message == "" {
+		return errors.New("invalid request")
+	}
+
+	messages := []string{response.Message}
+	for _, e := range response.Errors {
+		if e.Message != "" {
+			messages = append(messages, e.Message)
+		}
+	}
+
+	return errors.New(strings.Join(messages, ": "))
+}
+
+type RepositoryResponse struct {
+	ID            int    `json:"id"`
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+func Repository(baseURL string, projectPath string, token string) (RepositoryResponse, error) {
+	url := baseURL + "/repos/" + projectPath
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return RepositoryResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return RepositoryResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return RepositoryResponse{}, ErrNotFound
+	case http.StatusOK:
+		var repository RepositoryResponse
+		err = json.Unmarshal(resp.Body, &repository)
+		if err != nil {
+			return RepositoryResponse{}, err
+		}
+
+		return repository, nil
+	default:
+		return RepositoryResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+func CreatePullRequest(baseURL string, projectPath string, token string, pullRequest NewPullRequest) (PullRequestResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/pulls"
+
+	resp, err := apiPost(url, token, pullRequest)
+	if err != nil {
+		return PullRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return PullRequestResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return PullRequestResponse{}, ErrNotFound
+	case http.StatusUnprocessableEntity:
+		return PullRequestResponse{}, apiError(resp.Body)
+	case http.StatusCreated:
+		var created PullRequestResponse
+		err = json.Unmarshal(resp.Body, &created)
+		if err != nil {
+			return PullRequestResponse{}, err
+		}
+
+		return created, nil
 	default:
 		return PullRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
 	}
 }
+
+func PullRequest(baseURL string, projectPath string, token string, number int) (PullRequestResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/pulls/" + fmt.Sprint(number)
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return PullRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return PullRequestResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return PullRequestResponse{}, ErrNotFound
+	case http.StatusOK:
+		var pullRequest PullRequestResponse
+		err = json.Unmarshal(resp.Body, &pullRequest)
+		if err != nil {
+			return PullRequestResponse{}, err
+		}
+
+		return pullRequest, nil
+	default:
+		return PullRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type mergeRequest struct {
+	MergeMethod string `json:"merge_method,omitempty"`
+}
+
+// Merge pull request with "merge", "squash" or "rebase" method, empty method
+// uses a merge commit. Errors of pull requests that can't be merged, e.g. due
+// to branch protection, contain the message returned by the API.
+func MergePullRequest(baseURL string, projectPath string, token string, number int, method string) error {
+	url := baseURL + "/repos/" + projectPath + "/pulls/" + fmt.Sprint(number) + "/merge"
+
+	resp, err := apiPut(url, token, mergeRequest{MergeMethod: method})
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusConflict, http.StatusUnprocessableEntity:
+		return apiError(resp.Body)
+	case http.StatusOK:
+		return nil
+	default:
+		return errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Delete branch of the repository. ErrNotFound is returned if it doesn't exist,
+// e.g. because it was deleted automatically after merging.
+func DeleteBranch(baseURL string, projectPath string, token string, branch string) error {
+	url := baseURL + "/repos/" + projectPath + "/git/refs/heads/" + providers.EscapePath(branch)
+
+	resp, err := apiDelete(url, token)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotFound, http.StatusUnprocessableEntity:
+		return ErrNotFound
+	case http.StatusForbidden:
+		return apiError(resp.Body)
+	case http.StatusNoContent:
+		return nil
+	default:
+		return errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type CommentResponse struct {
+	ID      int    `json:"id"`
+	HtmlURL string `json:"html_url"`
+}
+
+type newComment struct {
+	Body string `json:"body"`
+}
+
+// Add comment to the conversation of a pull request
+func CreateComment(baseURL string, projectPath string, token string, number int, body string) (CommentResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/issues/" + fmt.Sprint(number) + "/comments"
+
+	resp, err := apiPost(url, token, newComment{body})
+	if err != nil {
+		return CommentResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return CommentResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return CommentResponse{}, ErrNotFound
+	case http.StatusForbidden, http.StatusUnprocessableEntity:
+		return CommentResponse{}, apiError(resp.Body)
+	case http.StatusCreated:
+		var comment CommentResponse
+		err = json.Unmarshal(resp.Body, &comment)
+		if err != nil {
+			return CommentResponse{}, err
+		}
+
+		return comment, nil
+	default:
+		return CommentResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Review decisions
+const (
+	ReviewApproved         = "approved"
+	ReviewChangesRequested = "changes_requested"
+	ReviewRequired         = "review_required"
+	ReviewNone             = ""
+)
+
+type reviewResponse struct {
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	State string `json:"state"`
+}
+
+// Compute review decision from the latest review of each reviewer
+func ReviewDecision(baseURL string, projectPath string, token string, pullRequest PullRequestResponse) (string, error) {
+	url := baseURL + "/repos/" + projectPath + "/pulls/" + fmt.Sprint(pullRequest.Number) + "/reviews?per_page=100"
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ReviewNone, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ReviewNone, ErrUnauthorized
+	case http.StatusOK:
+		var reviews []reviewResponse
+		err = json.Unmarshal(resp.Body, &reviews)
+		if err != nil {
+			return ReviewNone, err
+		}
+
+		// Reviews are returned in chronological order, comments don't change the decision
+		latest := map[string]string{}
+		for _, review := range reviews {
+			if review.State == "APPROVED" || review.State == "CHANGES_REQUESTED" || review.State == "DISMISSED" {
+				latest[review.User.Login] = review.State
+			}
+		}
+
+		approved := false
+		for _, state := range latest {
+			if state == "CHANGES_REQUESTED" {
+				return ReviewChangesRequested, nil
+			}
+			if state == "APPROVED" {
+				approved = true
+			}
+		}
+
+		if approved {
+			return ReviewApproved, nil
+		}
+
+		if len(pullRequest.RequestedReviewers) > 0 {
+			return ReviewRequired, nil
+		}
+
+		return ReviewNone, nil
+	default:
+		return ReviewNone, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+// Combined CI states
+const (
+	ChecksSuccess = "success"
+	ChecksFailure = "failure"
+	ChecksPending = "pending"
+	ChecksNone    = ""
+)
+
+type combinedStatusResponse struct {
+	State      string `json:"state"`
+	TotalCount int    `json:"total_count"`
+}
+
+type checkRunsResponse struct {
+	CheckRuns []struct {
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_runs"`
+}
+
+// Combine commit statuses and check runs into a single CI state
+func CommitChecks(baseURL string, projectPath string, token string, sha string) (string, error) {
+	statusURL := baseURL + "/repos/" + projectPath + "/commits/" + sha + "/status"
+	resp, err := apiGet(statusURL, token)
+	if err != nil {
+		return ChecksNone, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ChecksNone, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+
+	var status combinedStatusResponse
+	err = json.Unmarshal(resp.Body, &status)
+	if err != nil {
+		return ChecksNone, err
+	}
+
+	checkRunsURL := baseURL + "/repos/" + projectPath + "/commits/" + sha + "/check-runs?per_page=100"
+	resp, err = apiGet(checkRunsURL, token)
+	if err != nil {
+		return ChecksNone, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ChecksNone, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+
+	var checkRuns checkRunsResponse
+	err = json.Unmarshal(resp.Body, &checkRuns)
+	if err != nil {
+		return ChecksNone, err
+	}
+
+	var states []string
+
+	// Combined status is "pending" when there are no statuses at all
+	if status.TotalCount > 0 {
+		states = append(states, status.State)
+	}
+
+	for _, run := range checkRuns.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			states = append(states, ChecksPending)
+		case run.Conclusion == "failure" || run.Conclusion == "timed_out" || run.Conclusion == "cancelled" || run.Conclusion == "action_required":
+			states = append(states, ChecksFailure)
+		default:
+			states = append(states, ChecksSuccess)
+		}
+	}
+
+	result := ChecksNone
+	for _, state := range states {
+		switch state {
+		case "failure", "error":
+			return ChecksFailure, nil
+		case ChecksPending:
+			result = ChecksPending
+		case ChecksSuccess:
+			if result == ChecksNone {
+				result = ChecksSuccess
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type ReleaseResponse struct {
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	HtmlURL string `json:"html_url"`
+}
+
+// Most recent published release, drafts and pre-releases are skipped
+func LatestRelease(baseURL string, projectPath string, token string) (ReleaseResponse, error) {
+	url := baseURL + "/repos/" + projectPath + "/releases/latest"
+
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ReleaseResponse{}, ErrUnauthorized
+	case http.StatusNotFound:
+		return ReleaseResponse{}, ErrNotFound
+	case http.StatusOK:
+		var release ReleaseResponse
+		err = json.Unmarshal(resp.Body, &release)
+		if err != nil {
+			return ReleaseResponse{}, err
+		}
+
+		return release, nil
+	default:
+		return ReleaseResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
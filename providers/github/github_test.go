@@ -0,0 +1,54 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFindPullRequestsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"missing repository", http.StatusNotFound, `{"message":"Not Found"}`, ErrRepositoryNotFound},
+		{"no pull requests", http.StatusOK, `[]`, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			_, err := FindPullRequests(server.URL, "owner/repo", "token", "feature", "")
+			if !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteBranchEscapesBranch(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := DeleteBranch(server.URL, "owner/repo", "token", "fix/#12 über")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/repos/owner/repo/git/refs/heads/fix/%2312%20%C3%BCber"
+	if path != want {
+		t.Errorf("got %s, want %s", path, want)
+	}
+}
@@ -1,58 +1,169 @@
 package gitlab
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wowu/pro/providers"
 )
 
-var ErrUnauthorized = errors.New("unauthorized")
-var ErrNotFound = errors.New("not found")
+var ErrUnauthorized = providers.ErrUnauthorized
+var ErrNotFound = providers.ErrNotFound
+var ErrRepositoryNotFound = providers.ErrRepositoryNotFound
 var ErrTokenExpired = errors.New("token expired")
+var ErrForbidden = errors.New("forbidden")
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// API base URL of gitlab.com
+const DefaultBaseURL = "https://gitlab.com/api/v4"
 
 type ApiResponse struct {
 	StatusCode int
 	Body       []byte
+	Header     http.Header
 }
 
 func apiGet(url string, token string) (ApiResponse, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return apiRequest("GET", url, token, nil)
+}
+
+func apiPost(url string, token string, payload interface{}) (ApiResponse, error) {
+	return apiRequest("POST", url, token, payload)
+}
+
+func apiPut(url string, token string, payload interface{}) (ApiResponse, error) {
+	return apiRequest("PUT", url, token, payload)
+}
+
+func apiRequest(method string, url string, token string, payload interface{}) (ApiResponse, error) {
+	var requestBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		requestBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, requestBody)
 	if err != nil {
 		return ApiResponse{}, err
 	}
 
 	req.Header.Set("PRIVATE-TOKEN", token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := providers.Do(req)
 	if err != nil {
 		return ApiResponse{}, err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return ApiResponse{}, err
 	}
 
-	return ApiResponse{resp.StatusCode, body}, nil
+	return ApiResponse{resp.StatusCode, body, resp.Header}, nil
+}
+
+// Get pages of a list endpoint following the X-Next-Page header, joined into
+// a single JSON array. Stops after limit items, 0 means all pages. A failed
+// page is returned as it is.
+func apiGetPages(pageURL string, token string, limit int) (ApiResponse, error) {
+	var items []json.RawMessage
+
+	for pageURL != "" {
+		resp, err := apiGet(pageURL, token)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+
+		var page []json.RawMessage
+		err = json.Unmarshal(resp.Body, &page)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		items = append(items, page...)
+		if limit > 0 && len(items) >= limit {
+			items = items[:limit]
+			break
+		}
+
+		pageURL = nextPageURL(pageURL, resp.Header)
+	}
+
+	body, err := json.Marshal(items)
+	return ApiResponse{http.StatusOK, body, nil}, err
+}
+
+// URL of the page from X-Next-Page header, empty on the last page
+func nextPageURL(pageURL string, header http.Header) string {
+	next := header.Get("X-Next-Page")
+	if next == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+
+	query := parsed.Query()
+	query.Set("page", next)
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// Error of a rejected request. GitLab tells tokens without the required scope
+// apart from expired or invalid ones in the response body.
+func authError(resp ApiResponse) error {
+	var body struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(resp.Body, &body)
+
+	switch {
+	case body.Error == "insufficient_scope":
+		return ErrInsufficientScope
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case strings.HasPrefix(body.ErrorDescription, "Token is expired"):
+		return ErrTokenExpired
+	default:
+		return ErrUnauthorized
+	}
 }
 
 type UserResponse struct {
-	ID int `json:"id"`
+	ID       int    `json:"id"`
+	Username string `json:"username"`
 }
 
-func User(token string) (UserResponse, error) {
-	url := "https://gitlab.com/api/v4/user"
+func User(baseURL string, token string) (UserResponse, error) {
+	url := baseURL + "/user"
 	resp, err := apiGet(url, token)
 	if err != nil {
 		return UserResponse{}, err
 	}
 
 	switch resp.StatusCode {
-	case http.StatusUnauthorized:
-		return UserResponse{}, ErrUnauthorized
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return UserResponse{}, authError(resp)
 	case http.StatusOK:
 		var user UserResponse
 		err = json.Unmarshal(resp.Body, &user)
@@ -66,49 +177,474 @@ func User(token string) (UserResponse, error) {
 	}
 }
 
+type TokenResponse struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Details of the personal access token used for the request. Available since GitLab 15.5.
+func Token(baseURL string, token string) (TokenResponse, error) {
+	url := baseURL + "/personal_access_tokens/self"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return TokenResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return TokenResponse{}, ErrNotFound
+	case http.StatusOK:
+		var tokenResponse TokenResponse
+		err = json.Unmarshal(resp.Body, &tokenResponse)
+		if err != nil {
+			return TokenResponse{}, err
+		}
+
+		return tokenResponse, nil
+	default:
+		return TokenResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
 type MergeRequestResponse struct {
-	ID           int    `json:"id"`
-	Title        string `json:"title"`
-	State        string `json:"state"`
-	SourceBranch string `json:"source_branch"`
-	WebUrl       string `json:"web_url"`
+	ID                  int       `json:"id"`
+	IID                 int       `json:"iid"`
+	Title               string    `json:"title"`
+	Description         string    `json:"description"`
+	State               string    `json:"state"`
+	Draft               bool      `json:"draft"`
+	SourceBranch        string    `json:"source_branch"`
+	TargetBranch        string    `json:"target_branch"`
+	SourceProjectID     int       `json:"source_project_id"`
+	SHA                 string    `json:"sha"`
+	WebUrl              string    `json:"web_url"`
+	DetailedMergeStatus string    `json:"detailed_merge_status"`
+	HasConflicts        bool      `json:"has_conflicts"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	Author              struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Reviewers []struct {
+		Username string `json:"username"`
+	} `json:"reviewers"`
+	// Only returned for a single merge request
+	HeadPipeline *struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+		WebUrl string `json:"web_url"`
+	} `json:"head_pipeline"`
+}
+
+// URL of the pipelines tab
+func (m MergeRequestResponse) PipelinesURL() string {
+	return m.WebUrl + "/pipelines"
+}
+
+// Older GitLab versions only mark drafts with a title prefix
+func (m MergeRequestResponse) IsDraft() bool {
+	return m.Draft || HasDraftPrefix(m.Title)
+}
+
+// Check if title marks merge request as a draft, e.g. "Draft: Fix login"
+func HasDraftPrefix(title string) bool {
+	lower := strings.ToLower(title)
+	for _, prefix := range []string{"draft:", "[draft]", "(draft)", "wip:", "[wip]"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// API URL of open merge requests from given branch
+func FindMergeRequestURL(baseURL string, projectPath string, branch string, base string) string {
+	findURL := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests?state=opened&per_page=100&source_branch=" + url.QueryEscape(branch)
+	if base != "" {
+		findURL += "&target_branch=" + url.QueryEscape(base)
+	}
+
+	return findURL
+}
+
+// Open merge requests from given branch. Non-empty base limits them to those targeting that branch.
+func FindMergeRequests(baseURL string, projectPath string, token string, branch string, base string) ([]MergeRequestResponse, error) {
+	resp, err := apiGetPages(FindMergeRequestURL(baseURL, projectPath, branch, base), token, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, authError(resp)
+	case http.StatusNotFound:
+		// Returned for private projects the token can't access as well
+		return nil, ErrRepositoryNotFound
+	case http.StatusOK:
+		var mergeRequests []MergeRequestResponse
+		err = json.Unmarshal(resp.Body, &mergeRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(mergeRequests) == 0 {
+			return nil, ErrNotFound
+		}
+
+		return mergeRequests, nil
+	default:
+		return nil, errors.New("unknown response code")
+	}
+}
+
+// Open merge requests of target project opened from branch of a fork. Merge
+// requests from branches with the same name in other forks are skipped.
+func FindForkMergeRequests(baseURL string, targetPath string, token string, branch string, base string, sourceProjectID int) ([]MergeRequestResponse, error) {
+	mergeRequests, err := FindMergeRequests(baseURL, targetPath, token, branch, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []MergeRequestResponse
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.SourceProjectID == sourceProjectID {
+			found = append(found, mergeRequest)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return found, nil
+}
+
+// Merge request lookup on GitLab, see providers.Provider
+type Provider struct {
+	BaseURL string
+}
+
+func (p Provider) FindPullRequests(projectPath string, token string, branch string, base string) ([]providers.PullRequest, error) {
+	mergeRequests, err := FindMergeRequests(p.BaseURL, projectPath, token, branch, base)
+	return pullRequests(mergeRequests), err
+}
+
+// Same as FindForkMergeRequests, returning common pull requests
+func (p Provider) FindForkPullRequests(targetPath string, token string, branch string, base string, sourceProjectID int) ([]providers.PullRequest, error) {
+	mergeRequests, err := FindForkMergeRequests(p.BaseURL, targetPath, token, branch, base, sourceProjectID)
+	return pullRequests(mergeRequests), err
+}
+
+func pullRequests(mergeRequests []MergeRequestResponse) []providers.PullRequest {
+	var pullRequests []providers.PullRequest
+	for _, mergeRequest := range mergeRequests {
+		pullRequests = append(pullRequests, providers.PullRequest{
+			Number:    mergeRequest.IID,
+			Title:     mergeRequest.Title,
+			URL:       mergeRequest.WebUrl,
+			Draft:     mergeRequest.IsDraft(),
+			Author:    mergeRequest.Author.Username,
+			HeadSHA:   mergeRequest.SHA,
+			ChecksURL: mergeRequest.PipelinesURL(),
+		})
+	}
+
+	return pullRequests
+}
+
+// Open merge requests, most recent first. Limit caps their number, 0 means all.
+func ListMergeRequests(baseURL string, projectPath string, token string, limit int) ([]MergeRequestResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests?state=opened&per_page=100"
+	resp, err := apiGetPages(url, token, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, authError(resp)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusOK:
+		var mergeRequests []MergeRequestResponse
+		err = json.Unmarshal(resp.Body, &mergeRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeRequests, nil
+	default:
+		return nil, errors.New("unknown response code")
+	}
+}
+
+// Error message returned by the API. GitLab returns either a string or a list of messages.
+func apiError(body []byte) error {
+	var response struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return errors.New("invalid request")
+	}
+
+	switch message := response.Message.(type) {
+	case string:
+		return errors.New(message)
+	case []interface{}:
+		if len(message) > 0 {
+			return errors.New(fmt.Sprint(message[0]))
+		}
+	}
+
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+
+	return errors.New("invalid request")
 }
 
-func FindMergeRequest(projectPath string, token string, branch string) (MergeRequestResponse, error) {
-	url := "https://gitlab.com/api/v4/projects/" + url.QueryEscape(projectPath) + "/merge_requests?state=opened&source_branch=" + url.QueryEscape(branch)
+type ProjectResponse struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+	// Project this one was forked from, nil if it's not a fork
+	ForkedFromProject *struct {
+		ID                int    `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"forked_from_project"`
+}
+
+func Project(baseURL string, projectPath string, token string) (ProjectResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath)
 	resp, err := apiGet(url, token)
+	if err != nil {
+		return ProjectResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ProjectResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return ProjectResponse{}, ErrNotFound
+	case http.StatusOK:
+		var project ProjectResponse
+		err = json.Unmarshal(resp.Body, &project)
+		if err != nil {
+			return ProjectResponse{}, err
+		}
+
+		return project, nil
+	default:
+		return ProjectResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type NewMergeRequest struct {
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+}
+
+func CreateMergeRequest(baseURL string, projectPath string, token string, mergeRequest NewMergeRequest) (MergeRequestResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests"
+	resp, err := apiPost(url, token, mergeRequest)
 	if err != nil {
 		return MergeRequestResponse{}, err
 	}
 
 	switch resp.StatusCode {
 	case http.StatusUnauthorized:
-		var body map[string]interface{}
-		err = json.Unmarshal(resp.Body, &body)
+		return MergeRequestResponse{}, ErrUnauthorized
+	case http.StatusForbidden:
+		return MergeRequestResponse{}, ErrForbidden
+	case http.StatusNotFound:
+		return MergeRequestResponse{}, ErrNotFound
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity:
+		return MergeRequestResponse{}, apiError(resp.Body)
+	case http.StatusCreated:
+		var created MergeRequestResponse
+		err = json.Unmarshal(resp.Body, &created)
 		if err != nil {
 			return MergeRequestResponse{}, err
 		}
 
-		if body["error_description"] == "Token is expired. You can either do re-authorization or token refresh." {
-			return MergeRequestResponse{}, ErrTokenExpired
-		} else {
-			return MergeRequestResponse{}, ErrUnauthorized
+		return created, nil
+	default:
+		return MergeRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+func MergeRequest(baseURL string, projectPath string, token string, iid int) (MergeRequestResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests/" + fmt.Sprint(iid)
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return MergeRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return MergeRequestResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return MergeRequestResponse{}, ErrNotFound
+	case http.StatusOK:
+		var mergeRequest MergeRequestResponse
+		err = json.Unmarshal(resp.Body, &mergeRequest)
+		if err != nil {
+			return MergeRequestResponse{}, err
 		}
+
+		return mergeRequest, nil
+	default:
+		return MergeRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type AcceptMergeRequest struct {
+	Squash                   bool `json:"squash,omitempty"`
+	ShouldRemoveSourceBranch bool `json:"should_remove_source_branch,omitempty"`
+}
+
+// Merge merge request with the merge method set in project settings. Errors
+// of merge requests that can't be merged, e.g. due to conflicts or a failed
+// pipeline, contain the message returned by the API.
+func MergeMergeRequest(baseURL string, projectPath string, token string, iid int, accept AcceptMergeRequest) (MergeRequestResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests/" + fmt.Sprint(iid) + "/merge"
+	resp, err := apiPut(url, token, accept)
+	if err != nil {
+		return MergeRequestResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return MergeRequestResponse{}, authError(resp)
 	case http.StatusNotFound:
 		return MergeRequestResponse{}, ErrNotFound
+	case http.StatusMethodNotAllowed, http.StatusNotAcceptable, http.StatusConflict, http.StatusUnprocessableEntity:
+		return MergeRequestResponse{}, apiError(resp.Body)
 	case http.StatusOK:
-		var mergeRequests []MergeRequestResponse
-		err = json.Unmarshal(resp.Body, &mergeRequests)
+		var merged MergeRequestResponse
+		err = json.Unmarshal(resp.Body, &merged)
 		if err != nil {
 			return MergeRequestResponse{}, err
 		}
 
-		if len(mergeRequests) == 0 {
-			return MergeRequestResponse{}, ErrNotFound
+		return merged, nil
+	default:
+		return MergeRequestResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type NoteResponse struct {
+	ID int `json:"id"`
+}
+
+type newNote struct {
+	Body string `json:"body"`
+}
+
+// Add comment to a merge request. Link to it is the merge request URL with "#note_<id>".
+func CreateComment(baseURL string, projectPath string, token string, iid int, body string) (NoteResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests/" + fmt.Sprint(iid) + "/notes"
+	resp, err := apiPost(url, token, newNote{body})
+	if err != nil {
+		return NoteResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return NoteResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return NoteResponse{}, ErrNotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return NoteResponse{}, apiError(resp.Body)
+	case http.StatusCreated:
+		var note NoteResponse
+		err = json.Unmarshal(resp.Body, &note)
+		if err != nil {
+			return NoteResponse{}, err
+		}
+
+		return note, nil
+	default:
+		return NoteResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type ApprovalsResponse struct {
+	Approved      bool `json:"approved"`
+	ApprovalsLeft int  `json:"approvals_left"`
+	ApprovedBy    []struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	} `json:"approved_by"`
+}
+
+func Approvals(baseURL string, projectPath string, token string, iid int) (ApprovalsResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/merge_requests/" + fmt.Sprint(iid) + "/approvals"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ApprovalsResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ApprovalsResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return ApprovalsResponse{}, ErrNotFound
+	case http.StatusOK:
+		var approvals ApprovalsResponse
+		err = json.Unmarshal(resp.Body, &approvals)
+		if err != nil {
+			return ApprovalsResponse{}, err
+		}
+
+		return approvals, nil
+	default:
+		return ApprovalsResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
+	}
+}
+
+type ReleaseResponse struct {
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	Links   struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// Most recent release by release date
+func LatestRelease(baseURL string, projectPath string, token string) (ReleaseResponse, error) {
+	url := baseURL + "/projects/" + url.QueryEscape(projectPath) + "/releases?order_by=released_at&sort=desc&per_page=1"
+	resp, err := apiGet(url, token)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ReleaseResponse{}, authError(resp)
+	case http.StatusNotFound:
+		return ReleaseResponse{}, ErrNotFound
+	case http.StatusOK:
+		var releases []ReleaseResponse
+		err = json.Unmarshal(resp.Body, &releases)
+		if err != nil {
+			return ReleaseResponse{}, err
+		}
+
+		if len(releases) == 0 {
+			return ReleaseResponse{}, ErrNotFound
 		}
 
-		return mergeRequests[0], nil
+		return releases[0], nil
 	default:
-		return MergeRequestResponse{}, errors.New("unknown response code")
+		return ReleaseResponse{}, errors.New("unknown response code: " + fmt.Sprint(resp.StatusCode))
 	}
 }
@@ -0,0 +1,174 @@
+package providers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wowu/pro/debug"
+)
+
+// Attempts made for GET requests failing with a network or server error
+var MaxAttempts = 3
+
+// Set by the --no-retry flag
+var NoRetry bool
+
+// Time limit for a single request
+var Timeout = 10 * time.Second
+
+// Returned when a request doesn't finish within Timeout
+var ErrTimeout = errors.New("request timed out")
+
+// Proxy for all requests, set by the --proxy flag or "proxy" config key.
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used when nil.
+var Proxy *url.URL
+
+// User-Agent of all requests, "pro/<version>" unless set with "user_agent" config key
+var UserAgent = "pro"
+
+// Headers added to all requests, set with "headers" config key, e.g. for a gateway
+// in front of a self-hosted instance
+var Headers map[string]string
+
+// Certificate authorities trusted in addition to the system ones, set by LoadCACert
+var rootCAs *x509.CertPool
+
+// Skip TLS certificate verification, set by the --insecure flag
+var Insecure bool
+
+// Delay before the first retry, doubled after each attempt
+var retryDelay = 500 * time.Millisecond
+
+// Send request, retrying GET requests on transient failures with exponential backoff.
+// Other methods are sent once, as they may not be safe to repeat.
+func Do(req *http.Request) (*http.Response, error) {
+	attempts := MaxAttempts
+	if NoRetry || req.Method != http.MethodGet || attempts < 1 {
+		attempts = 1
+	}
+
+	client := Client()
+	delay := retryDelay
+
+	for attempt := 1; ; attempt++ {
+		debug.Printf("%s %s", req.Method, req.URL)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			debug.Printf("Request failed: %s", err)
+		} else {
+			debug.Printf("Response status: %d", resp.StatusCode)
+		}
+
+		if attempt >= attempts || !isTransient(resp, err) {
+			if isTimeout(err) {
+				return nil, fmt.Errorf("%w after %s, use --timeout flag to wait longer", ErrTimeout, Timeout)
+			}
+
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		debug.Printf("Retrying in %s (attempt %d of %d)", delay, attempt+1, attempts)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// HTTP client with configured timeout and proxy
+func Client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if Proxy != nil {
+		transport.Proxy = http.ProxyURL(Proxy)
+	}
+	if rootCAs != nil || Insecure {
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, InsecureSkipVerify: Insecure}
+	}
+
+	return &http.Client{Timeout: Timeout, Transport: headerTransport{transport}}
+}
+
+// Transport setting User-Agent and configured headers on each request
+type headerTransport struct {
+	base http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests must not be modified by a RoundTripper
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", UserAgent)
+	for name, value := range Headers {
+		req.Header.Set(name, value)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Trust certificates from PEM file in addition to the system ones, e.g. an
+// internal CA of a self-hosted instance
+func LoadCACert(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificates found in %s", path)
+	}
+
+	rootCAs = pool
+	return nil
+}
+
+// Parse proxy URL, e.g. "http://proxy.acme.com:8080". Scheme defaults to http.
+func ParseProxy(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("missing host in proxy URL %q", proxy)
+	}
+
+	return proxyURL, nil
+}
+
+// Server errors, timeouts and dropped connections are worth retrying.
+// Client errors like 401 or 404 won't change on retry.
+func isTransient(resp *http.Response, err error) bool {
+	if err == nil {
+		return resp.StatusCode >= 500
+	}
+
+	return isTimeout(err) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
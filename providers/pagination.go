@@ -0,0 +1,23 @@
+package providers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// URL of the next page from a Link header used by GitHub and Gitea, e.g.
+// `<https://api.github.com/repositories/1/pulls?page=2>; rel="next"`.
+// Empty on the last page.
+func NextPageURL(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, _ := strings.Cut(link, ";")
+
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+
+	return ""
+}
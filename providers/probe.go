@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// API endpoints answering without a token, with a field present only in
+// responses of given provider
+var probes = []struct {
+	Type  string
+	Path  string
+	Field string
+}{
+	{"github", "/api/v3/meta", "verifiable_password_authentication"},
+	{"gitlab", "/api/v4/version", "version"},
+	{"gitea", "/api/v1/version", "version"},
+}
+
+// Detect provider of a self-hosted instance by requesting API endpoints of
+// each provider. Returns false if none of them answers.
+func Probe(host string) (string, bool) {
+	for _, probe := range probes {
+		if probeEndpoint("https://"+host+probe.Path, probe.Field) {
+			return probe.Type, true
+		}
+	}
+
+	return "", false
+}
+
+func probeEndpoint(url string, field string) bool {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	// GitLab may require a token for the version, its API responses have this header anyway
+	if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("X-Gitlab-Meta") != "" {
+		return true
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return false
+	}
+
+	_, ok := fields[field]
+	return ok
+}
@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Errors returned by all providers, so they can be checked without knowing
+// which provider the request was sent to
+var ErrUnauthorized = errors.New("unauthorized")
+var ErrNotFound = errors.New("not found")
+
+// Repository doesn't exist or the token has no access to it, unlike
+// ErrNotFound, which means there are no matching pull requests
+var ErrRepositoryNotFound = errors.New("repository not found")
+
+// Open pull request, common for all providers
+type PullRequest struct {
+	Number  int
+	Title   string
+	URL     string
+	Draft   bool
+	Author  string
+	HeadSHA string
+	// CI status page, empty if provider doesn't have one
+	ChecksURL string
+}
+
+// Git hosting service with pull requests. Adding a provider type only takes
+// implementing this interface and registering it for the host type.
+type Provider interface {
+	// Open pull requests from branch, targeting base if it's not empty.
+	// ErrNotFound is returned if there are none.
+	FindPullRequests(projectPath string, token string, branch string, base string) ([]PullRequest, error)
+}
+
+// Escape each segment of a path, keeping slashes, e.g. for branch "fix/über-bug"
+func EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return strings.Join(segments, "/")
+}